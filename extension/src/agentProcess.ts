@@ -4,6 +4,7 @@ import * as readline from 'readline';
 const PROMPT_LINE = 'You: ';
 const AGENT_PREFIX = 'Agent: ';
 const TOOL_PREFIX = 'tool: ';
+const EVENT_PREFIX = 'event: ';
 
 function stripAnsi(s: string): string {
 	return s.replace(/\x1b\[[0-9;]*m/g, '');
@@ -18,9 +19,21 @@ export interface AgentToolCall {
 	input?: string;
 }
 
+/** Structured event emitted by a Go tool (AGENT_EVENTS=json), e.g. a file_diff or command. */
+export interface AgentEvent {
+	type: string;
+	tool?: string;
+	path?: string;
+	diff?: string;
+	command?: string;
+	risk?: string;
+	reasons?: string[];
+}
+
 export interface AgentTurnResult {
 	messages: AgentTurnMessage[];
 	toolCalls: AgentToolCall[];
+	events: AgentEvent[];
 }
 
 export class AgentProcess {
@@ -45,6 +58,7 @@ export class AgentProcess {
 			}
 			const messages: AgentTurnMessage[] = [];
 			const toolCalls: AgentToolCall[] = [];
+			const events: AgentEvent[] = [];
 			const rl = readline.createInterface({
 				input: this.process.stdout!,
 				crlfDelay: Infinity
@@ -59,7 +73,15 @@ export class AgentProcess {
 					}
 					rl.removeListener('line', onLine);
 					rl.close();
-					resolve({ messages, toolCalls });
+					resolve({ messages, toolCalls, events });
+					return;
+				}
+				if (plain.startsWith(EVENT_PREFIX)) {
+					try {
+						events.push(JSON.parse(plain.slice(EVENT_PREFIX.length)));
+					} catch {
+						// Ignore malformed event lines.
+					}
 					return;
 				}
 				if (plain.startsWith(TOOL_PREFIX)) {
@@ -113,7 +135,7 @@ export class AgentProcess {
 		}
 		this.process = child_process.spawn(this.binPath, [], {
 			cwd: this.cwd,
-			env: { ...process.env, ANTHROPIC_API_KEY: this.apiKey },
+			env: { ...process.env, ANTHROPIC_API_KEY: this.apiKey, AGENT_EVENTS: 'json' },
 			stdio: ['pipe', 'pipe', 'pipe']
 		});
 		this.process.on('error', (err) => {
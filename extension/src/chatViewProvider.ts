@@ -71,7 +71,8 @@ async function handleSend(
 		panel.webview.postMessage({
 			type: 'agentTurn',
 			messages: result.messages,
-			toolCalls: result.toolCalls
+			toolCalls: result.toolCalls,
+			events: result.events
 		});
 	} catch (e) {
 		const message = e instanceof Error ? e.message : String(e);
@@ -111,6 +112,10 @@ function getHtml(webview: vscode.Webview): string {
 		.msg.user { background: var(--vscode-input-background); }
 		.msg.agent { background: var(--vscode-editor-inactiveSelectionBackground); white-space: pre-wrap; word-break: break-word; }
 		.msg.tool { font-size: 0.9em; color: var(--vscode-descriptionForeground); }
+		.msg.diff { font-family: var(--vscode-editor-font-family); font-size: 0.85em; white-space: pre; overflow-x: auto; background: var(--vscode-textCodeBlock-background); }
+		.diff .add { color: var(--vscode-gitDecoration-addedResourceForeground); }
+		.diff .del { color: var(--vscode-gitDecoration-deletedResourceForeground); }
+		.diff .hunk { color: var(--vscode-descriptionForeground); }
 		#inputRow { display: flex; gap: 6px; margin-top: 8px; }
 		#input { flex: 1; padding: 6px 8px; border: 1px solid var(--vscode-input-border); background: var(--vscode-input-background); color: var(--vscode-input-foreground); border-radius: 4px; }
 		button { padding: 6px 12px; background: var(--vscode-button-background); color: var(--vscode-button-foreground); border: none; border-radius: 4px; cursor: pointer; }
@@ -142,6 +147,25 @@ function getHtml(webview: vscode.Webview): string {
 			messagesEl.scrollTop = messagesEl.scrollHeight;
 		}
 
+		function appendDiff(diff) {
+			const div = document.createElement('div');
+			div.className = 'msg diff';
+			diff.replace(/\\n$/, '').split('\\n').forEach(line => {
+				const span = document.createElement('span');
+				if (line.startsWith('@@')) {
+					span.className = 'hunk';
+				} else if (line.startsWith('+') && !line.startsWith('+++')) {
+					span.className = 'add';
+				} else if (line.startsWith('-') && !line.startsWith('---')) {
+					span.className = 'del';
+				}
+				span.textContent = line + '\\n';
+				div.appendChild(span);
+			});
+			messagesEl.appendChild(div);
+			messagesEl.scrollTop = messagesEl.scrollHeight;
+		}
+
 		window.addEventListener('message', e => {
 			const msg = e.data;
 			switch (msg.type) {
@@ -158,6 +182,9 @@ function getHtml(webview: vscode.Webview): string {
 				case 'agentTurn':
 					thinkingEl.style.display = 'none';
 					(msg.toolCalls || []).forEach(t => appendMessage('tool', 'tool: ' + t.name + '(' + (t.input || '') + ')', true));
+					(msg.events || []).filter(ev => ev.type === 'file_diff' && ev.diff).forEach(ev => appendDiff(ev.diff));
+					(msg.events || []).filter(ev => ev.type === 'command' && ev.risk && ev.risk !== 'read-only')
+						.forEach(ev => appendMessage('tool', 'risk: ' + ev.risk + ': ' + (ev.reasons || []).join('; '), true));
 					(msg.messages || []).forEach(m => appendMessage('agent', m.text || m, false));
 					break;
 				case 'injectMainGoContent':
@@ -5,9 +5,13 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"agentExample/tools"
 
@@ -15,6 +19,7 @@ import (
 )
 
 func main() {
+	tools.SandboxInit()
 	client := anthropic.NewClient()
 	scanner := bufio.NewScanner(os.Stdin)
 	getUserMessage := func() (string, bool) {
@@ -31,15 +36,54 @@ func main() {
 		tools.GetWorkingDirDefinition, tools.MoveFileDefinition, tools.CopyFileDefinition,
 		tools.FileInfoDefinition, tools.ListFilesRecursiveDefinition, tools.ReadFileLinesDefinition,
 		tools.CreateDirectoryDefinition, tools.RemoveDirectoryDefinition,
-		tools.SearchInternetDefinition, tools.FetchHTMLDefinition, tools.FetchFileDefinition,
+		tools.SearchInternetDefinition, tools.FetchHTMLDefinition, tools.HTMLQueryDefinition, tools.FetchFileDefinition,
+		tools.GitStatusDefinition, tools.GitDiffDefinition, tools.GitLogDefinition, tools.GitShowDefinition,
+		tools.GitBlameDefinition, tools.GitBranchDefinition, tools.GitCommitDefinition,
+		tools.MultiEditDefinition, tools.ApplyPatchDefinition, tools.FindFilesDefinition,
+		tools.GoOutlineDefinition, tools.GoReadSymbolDefinition,
+		tools.GoDefinitionDefinition, tools.GoReferencesDefinition, tools.GoImplementationsDefinition,
+		tools.GoRenameDefinition, tools.GoTestDefinition, tools.GoCheckDefinition, tools.LintDefinition,
+		tools.ProcessStartDefinition, tools.ProcessReadDefinition, tools.ProcessStatusDefinition,
+		tools.ProcessSendDefinition, tools.ProcessStopDefinition, tools.ShellDefinition,
 	}
+	tools.SetApprovalFunc(approvalPolicy(os.Getenv("AGENT_APPROVAL"), getUserMessage))
+	tools.SetEventSink(eventPrinter(os.Getenv("AGENT_EVENTS")))
+	tools.SetRefuseGoSyntaxErrors(os.Getenv("AGENT_GO_SYNTAX_ERRORS") == "refuse")
+	if err := configureSandbox(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	stopBackgroundOnSignal()
 	agent := NewAgent(&client, getUserMessage, agentTools)
 	err := agent.Run(context.Background())
+	tools.StopBackgroundProcesses()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error:", err)
 	}
 }
 
+// configureSandbox enables the command sandbox when AGENT_SANDBOX=on. It is an error if the sandbox
+// was asked for but cannot be created, rather than silently running commands unsandboxed.
+func configureSandbox() error {
+	cfg, err := tools.SandboxConfigFromEnv()
+	if err != nil || cfg == nil {
+		return err
+	}
+	return tools.SetSandbox(cfg)
+}
+
+// stopBackgroundOnSignal stops the agent's background processes before exiting on Ctrl+C or SIGTERM,
+// since they run in their own process groups and would otherwise outlive the agent.
+func stopBackgroundOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		tools.StopBackgroundProcesses()
+		os.Exit(130)
+	}()
+}
+
 // Agent holds the API client, user input source, and available tools for a chat run.
 type Agent struct {
 	client         *anthropic.Client
@@ -89,6 +133,11 @@ func (a *Agent) Run(ctx context.Context) error {
 			fmt.Println("Context cleared. You can continue with a fresh conversation.")
 			continue
 		}
+		if reply, handled := checkpointCommand(userInput); handled {
+			fmt.Println(reply)
+			continue
+		}
+		tools.Checkpoints.BeginTurn(userInput)
 
 		userMessage := anthropic.NewUserMessage(anthropic.NewTextBlock(userInput))
 		conversation = append(conversation, userMessage)
@@ -184,6 +233,119 @@ func (a *Agent) runInterface(ctx context.Context, conversation *[]anthropic.Mess
 	return message, nil
 }
 
+// approvalPolicy returns the approval function for guarded tools. policy is "allow", "deny" or "prompt";
+// when empty, the user is prompted if stdin is a terminal and everything is allowed otherwise
+// (e.g. when driven by the VS Code extension).
+func approvalPolicy(policy string, getUserMessage func() (string, bool)) tools.ApprovalFunc {
+	if policy == "" {
+		policy = "allow"
+		if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			policy = "prompt"
+		}
+	}
+	switch policy {
+	case "deny":
+		return func(tools.ApprovalRequest) bool { return false }
+	case "prompt":
+		return func(req tools.ApprovalRequest) bool {
+			tool := req.Tool
+			if req.Risk != "" {
+				tool += " [" + req.Risk + "]"
+			}
+			fmt.Printf("\033[95mApprove\033[0m %s: %s? [y/N] ", tool, req.Summary)
+			answer, ok := getUserMessage()
+			if !ok {
+				return false
+			}
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			return answer == "y" || answer == "yes"
+		}
+	default:
+		return func(tools.ApprovalRequest) bool { return true }
+	}
+}
+
+// eventPrinter returns the sink for tool events. With mode "json" each event is printed as a single
+// "event: {...}" line for front-ends such as the VS Code extension; otherwise diffs are printed in
+// color, and so is the risk of shell commands that are more than read-only.
+func eventPrinter(mode string) func(tools.Event) {
+	if mode == "json" {
+		return func(e tools.Event) {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return
+			}
+			fmt.Printf("event: %s\n", b)
+		}
+	}
+	return func(e tools.Event) {
+		if e.Type == tools.EventCommand {
+			if e.Risk != tools.RiskReadOnly.String() {
+				fmt.Printf("\033[33m  risk: %s: %s\033[0m\n", e.Risk, strings.Join(e.Reasons, "; "))
+			}
+			return
+		}
+		if e.Type != tools.EventFileDiff {
+			return
+		}
+		for _, line := range strings.Split(strings.TrimRight(e.Diff, "\n"), "\n") {
+			switch {
+			case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+				fmt.Printf("\033[1m%s\033[0m\n", line)
+			case strings.HasPrefix(line, "@@"):
+				fmt.Printf("\033[36m%s\033[0m\n", line)
+			case strings.HasPrefix(line, "+"):
+				fmt.Printf("\033[32m%s\033[0m\n", line)
+			case strings.HasPrefix(line, "-"):
+				fmt.Printf("\033[31m%s\033[0m\n", line)
+			default:
+				fmt.Println(line)
+			}
+		}
+	}
+}
+
+// checkpointCommand handles /undo, /checkpoints and /restore <n>; handled is false for any other input.
+func checkpointCommand(userInput string) (reply string, handled bool) {
+	fields := strings.Fields(userInput)
+	switch fields[0] {
+	case "/undo":
+		reply, err := tools.Checkpoints.Undo()
+		if err != nil {
+			return err.Error(), true
+		}
+		return reply, true
+	case "/checkpoints":
+		checkpoints := tools.Checkpoints.List()
+		if len(checkpoints) == 0 {
+			return "No checkpoints yet.", true
+		}
+		var b strings.Builder
+		for _, c := range checkpoints {
+			label := c.Label
+			if r := []rune(label); len(r) > 60 {
+				label = string(r[:60]) + "..."
+			}
+			fmt.Fprintf(&b, "#%d %s %q: %s\n", c.ID, c.Created.Format("15:04:05"), label, strings.Join(c.Paths(), ", "))
+		}
+		return strings.TrimRight(b.String(), "\n"), true
+	case "/restore":
+		if len(fields) != 2 {
+			return "Usage: /restore <n> (see /checkpoints)", true
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(fields[1], "#"))
+		if err != nil {
+			return "Usage: /restore <n> (see /checkpoints)", true
+		}
+		reply, err := tools.Checkpoints.Restore(id)
+		if err != nil {
+			return err.Error(), true
+		}
+		return reply, true
+	}
+	return "", false
+}
+
 func findTool(agentTools []tools.ToolDefinition, name string) *tools.ToolDefinition {
 	for i := range agentTools {
 		if agentTools[i].Name == name {
@@ -0,0 +1,508 @@
+// Package tools provides the apply_patch tool for the agent.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ApplyPatchDefinition is the tool that applies a unified or git-style diff to the working tree.
+var ApplyPatchDefinition = ToolDefinition{
+	Name:        "apply_patch",
+	Description: "Apply a unified diff (as produced by diff -u or git diff) to files in the working directory. Supports several files per patch, new files (--- /dev/null), deleted files (+++ /dev/null) and git renames (rename from/rename to). Hunks are located at their stated line or, if the file has shifted, at the nearest position where their context matches; each hunk's result is reported. The patch is applied all-or-nothing: if any hunk fails, nothing is written. Set check to validate without writing.",
+	InputSchema: ApplyPatchInputSchema,
+	Function:    ApplyPatch,
+}
+
+// ApplyPatchInput is the JSON shape for the apply_patch tool.
+type ApplyPatchInput struct {
+	Patch string `json:"patch" jsonschema_description:"The unified diff text to apply."`
+	Check bool   `json:"check" jsonschema_description:"If true, only check whether the patch applies cleanly (dry run); no files are changed."`
+}
+
+// ApplyPatchInputSchema is the Anthropic tool input schema for apply_patch.
+var ApplyPatchInputSchema = GenerateSchema[ApplyPatchInput]()
+
+// patchLine is one hunk body line: kind is ' ', '-' or '+'; text keeps its trailing newline unless
+// the patch marked it with "\ No newline at end of file".
+type patchLine struct {
+	kind byte
+	text string
+}
+
+type patchHunk struct {
+	header   string
+	oldStart int
+	oldCount int
+	newStart int
+	newCount int
+	lines    []patchLine
+}
+
+type filePatch struct {
+	oldPath  string
+	newPath  string
+	isNew    bool
+	isDelete bool
+	binary   bool
+	hunks    []*patchHunk
+}
+
+// targetPath is the path the patch writes to (the old path for deletions).
+func (fp *filePatch) targetPath() string {
+	if fp.isDelete {
+		return fp.oldPath
+	}
+	return fp.newPath
+}
+
+// ApplyPatch implements the apply_patch tool.
+func ApplyPatch(input json.RawMessage) (string, error) {
+	var in ApplyPatchInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("apply_patch input: %w", err)
+	}
+	patches, err := parsePatch(in.Patch)
+	if err != nil {
+		return "", fmt.Errorf("apply_patch: %w", err)
+	}
+
+	type result struct {
+		fp       *filePatch
+		original string
+		existed  bool
+		updated  string
+	}
+	var results []result
+	var report []string
+	failed := false
+	for _, fp := range patches {
+		label := fp.targetPath()
+		if fp.oldPath != "" && fp.newPath != "" && fp.oldPath != fp.newPath {
+			label = fp.oldPath + " -> " + fp.newPath
+		}
+		if fp.binary {
+			report = append(report, fmt.Sprintf("%s: binary patches are not supported", label))
+			failed = true
+			continue
+		}
+		var original string
+		existed := false
+		if !fp.isNew {
+			content, err := os.ReadFile(fp.oldPath)
+			if err != nil {
+				report = append(report, fmt.Sprintf("%s: %v", label, err))
+				failed = true
+				continue
+			}
+			original, existed = string(content), true
+		} else if _, err := os.Stat(fp.newPath); err == nil {
+			report = append(report, fmt.Sprintf("%s: patch creates the file but it already exists", label))
+			failed = true
+			continue
+		}
+		if !fp.isDelete && fp.newPath != fp.oldPath && !fp.isNew {
+			if _, err := os.Stat(fp.newPath); err == nil {
+				report = append(report, fmt.Sprintf("%s: rename target already exists", label))
+				failed = true
+				continue
+			}
+		}
+		updated, hunkReport, ok := applyHunks(original, fp.hunks)
+		if fp.isDelete && ok && updated != "" {
+			hunkReport = append(hunkReport, "file is not empty after removing the patch's lines; refusing to delete it")
+			ok = false
+		}
+		report = append(report, fmt.Sprintf("%s:", label))
+		for _, line := range hunkReport {
+			report = append(report, "  "+line)
+		}
+		if !ok {
+			failed = true
+			continue
+		}
+		results = append(results, result{fp: fp, original: original, existed: existed, updated: updated})
+	}
+
+	summary := strings.Join(report, "\n")
+	if failed {
+		return "", fmt.Errorf("apply_patch: patch does not apply; no files were changed\n%s", summary)
+	}
+	if in.Check {
+		return "Patch applies cleanly (check only, no files changed)\n" + summary, nil
+	}
+
+	for i, r := range results {
+		if r.fp.isDelete {
+			continue
+		}
+		formatted, goNote, err := prepareGoWrite(r.fp.newPath, r.updated)
+		if err != nil {
+			return "", fmt.Errorf("apply_patch: %w; no files were changed", err)
+		}
+		results[i].updated = formatted
+		if goNote != "" {
+			summary += "\n" + goNote
+		}
+	}
+
+	var writes []pendingWrite
+	var snapshots []string
+	for _, r := range results {
+		fp := r.fp
+		switch {
+		case fp.isDelete:
+			writes = append(writes, pendingWrite{path: fp.oldPath, remove: true})
+			snapshots = append(snapshots, fp.oldPath)
+		case fp.oldPath != "" && fp.oldPath != fp.newPath && !fp.isNew:
+			mode := os.FileMode(0)
+			if info, err := os.Stat(fp.oldPath); err == nil {
+				mode = info.Mode().Perm()
+			}
+			writes = append(writes, pendingWrite{path: fp.newPath, content: []byte(r.updated), mode: mode}, pendingWrite{path: fp.oldPath, remove: true})
+			snapshots = append(snapshots, fp.oldPath, fp.newPath)
+		default:
+			writes = append(writes, pendingWrite{path: fp.newPath, content: []byte(r.updated)})
+			snapshots = append(snapshots, fp.newPath)
+		}
+	}
+	if err := snapshotForWrite(snapshots...); err != nil {
+		return "", err
+	}
+	if err := writeFilesAtomically(writes); err != nil {
+		return "", fmt.Errorf("apply_patch: %w", err)
+	}
+
+	var diffs strings.Builder
+	for _, r := range results {
+		diffs.WriteString(reportDiff("apply_patch", r.fp.targetPath(), r.original, r.updated, r.existed, r.fp.isDelete))
+	}
+	return fmt.Sprintf("Applied patch to %d file(s)\n%s\n\n%s", len(results), summary, diffForResult(diffs.String())), nil
+}
+
+// applyHunks applies hunks in order to content. Each hunk is tried at its stated position (shifted by
+// however far earlier hunks had to move) and then at the nearest position where its old lines match,
+// first exactly and then ignoring trailing whitespace. It returns the new content and one report line per hunk.
+func applyHunks(content string, hunks []*patchHunk) (string, []string, bool) {
+	var lines []string
+	if content != "" {
+		lines = splitLinesKeepEnds(content)
+	}
+	var out []string
+	var report []string
+	pos := 0
+	drift := 0 // how far the file has shifted relative to the patch, from earlier hunks
+	ok := true
+	for i, h := range hunks {
+		var oldLines, newLines []string
+		for _, l := range h.lines {
+			if l.kind != '+' {
+				oldLines = append(oldLines, l.text)
+			}
+			if l.kind != '-' {
+				newLines = append(newLines, l.text)
+			}
+		}
+		stated := h.oldStart - 1
+		if h.oldCount == 0 {
+			// A pure insertion goes after line oldStart.
+			stated = h.oldStart
+		}
+		want := stated + drift
+		at, loose := locateHunk(lines, oldLines, want, pos)
+		if at < 0 {
+			ok = false
+			report = append(report, fmt.Sprintf("hunk %d %s FAILED: context not found%s", i+1, h.header, nearbyContext(lines, min(want, len(lines)))))
+			continue
+		}
+		note := ""
+		if offset := at - stated; offset != 0 {
+			note = fmt.Sprintf(" (offset %+d lines)", offset)
+		}
+		if loose {
+			note += " (ignoring trailing whitespace)"
+		}
+		report = append(report, fmt.Sprintf("hunk %d %s applied at line %d%s", i+1, h.header, at+1, note))
+		if !ok {
+			continue
+		}
+		out = append(out, lines[pos:at]...)
+		out = append(out, newLines...)
+		pos = at + len(oldLines)
+		drift = at - stated
+	}
+	if !ok {
+		return "", report, false
+	}
+	out = append(out, lines[pos:]...)
+	return strings.Join(out, ""), report, true
+}
+
+// locateHunk returns the index at which old matches lines, searching outward from want but never
+// before min. loose reports that the match needed trailing whitespace to be ignored.
+func locateHunk(lines, old []string, want, min int) (int, bool) {
+	if want < min {
+		want = min
+	}
+	if want > len(lines) {
+		want = len(lines)
+	}
+	for _, loose := range []bool{false, true} {
+		matchAt := func(at int) bool {
+			if at < min || at+len(old) > len(lines) {
+				return false
+			}
+			for k, l := range old {
+				a, b := lines[at+k], l
+				if loose {
+					a, b = strings.TrimRight(a, " \t\r\n"), strings.TrimRight(b, " \t\r\n")
+				}
+				if a != b {
+					return false
+				}
+			}
+			return true
+		}
+		for d := 0; want-d >= min || want+d <= len(lines); d++ {
+			if matchAt(want - d) {
+				return want - d, loose
+			}
+			if d > 0 && matchAt(want+d) {
+				return want + d, loose
+			}
+		}
+	}
+	return -1, false
+}
+
+// nearbyContext shows the file lines around want so a failed hunk can be corrected.
+func nearbyContext(lines []string, want int) string {
+	if len(lines) == 0 {
+		return " (file is empty)"
+	}
+	start, end := want-3, want+4
+	if start < 0 {
+		start = 0
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	if start >= end {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "; file around line %d:", start+1)
+	for i := start; i < end; i++ {
+		fmt.Fprintf(&b, "\n      %d: %s", i+1, strings.TrimRight(lines[i], "\r\n"))
+	}
+	return b.String()
+}
+
+// parsePatch splits a unified or git-style diff into per-file patches.
+func parsePatch(patch string) ([]*filePatch, error) {
+	// A patch whose every line ends in \r\n was converted as a whole; otherwise a \r belongs to the
+	// content of a CRLF file and is kept in the hunk lines.
+	if strings.Count(patch, "\n") == strings.Count(patch, "\r\n") {
+		patch = strings.ReplaceAll(patch, "\r\n", "\n")
+	}
+	lines := strings.Split(patch, "\n")
+	var patches []*filePatch
+	var cur *filePatch
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\r")
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			cur = &filePatch{}
+			patches = append(patches, cur)
+			if a, b, ok := splitGitDiffHeader(strings.TrimPrefix(line, "diff --git ")); ok {
+				cur.oldPath, cur.newPath = a, b
+			}
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if cur == nil || len(cur.hunks) > 0 {
+				cur = &filePatch{}
+				patches = append(patches, cur)
+			}
+			oldName := patchFileName(strings.TrimPrefix(line, "--- "))
+			newName := patchFileName(strings.TrimPrefix(lines[i+1], "+++ "))
+			i++
+			if oldName == "/dev/null" {
+				cur.isNew = true
+				cur.oldPath = ""
+			} else {
+				cur.oldPath = oldName
+			}
+			if newName == "/dev/null" {
+				cur.isDelete = true
+				cur.newPath = ""
+			} else {
+				cur.newPath = newName
+			}
+		case cur != nil && strings.HasPrefix(line, "new file mode"):
+			cur.isNew = true
+		case cur != nil && strings.HasPrefix(line, "deleted file mode"):
+			cur.isDelete = true
+		case cur != nil && strings.HasPrefix(line, "rename from "):
+			cur.oldPath = strings.TrimPrefix(line, "rename from ")
+		case cur != nil && strings.HasPrefix(line, "rename to "):
+			cur.newPath = strings.TrimPrefix(line, "rename to ")
+		case cur != nil && (strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch"):
+			cur.binary = true
+		case strings.HasPrefix(line, "@@ "):
+			if cur == nil {
+				return nil, fmt.Errorf("hunk at line %d has no file header (--- / +++)", i+1)
+			}
+			h, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			cur.hunks = append(cur.hunks, h)
+			i = next - 1
+		}
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no file patches found; expected --- / +++ headers followed by @@ hunks")
+	}
+	var kept []*filePatch
+	for _, fp := range patches {
+		if fp.isNew {
+			fp.oldPath = ""
+		}
+		if fp.isDelete {
+			fp.newPath = ""
+		}
+		if fp.oldPath == "" && !fp.isNew {
+			fp.oldPath = fp.newPath
+		}
+		if fp.newPath == "" && !fp.isDelete {
+			fp.newPath = fp.oldPath
+		}
+		fp.oldPath = resolvePatchPath(fp.oldPath)
+		fp.newPath = resolvePatchPath(fp.newPath)
+		if fp.targetPath() == "" {
+			return nil, fmt.Errorf("file patch without a path")
+		}
+		if len(fp.hunks) == 0 && !fp.isDelete && !fp.isNew && fp.oldPath == fp.newPath && !fp.binary {
+			// e.g. a git mode-only change; there is no content to apply.
+			continue
+		}
+		kept = append(kept, fp)
+	}
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("patch contains no content changes")
+	}
+	return kept, nil
+}
+
+// parseHunk parses the hunk starting at lines[start] and returns it with the index of the next unread line.
+func parseHunk(lines []string, start int) (*patchHunk, int, error) {
+	header := lines[start]
+	end := strings.Index(header[3:], " @@")
+	if end < 0 {
+		return nil, 0, fmt.Errorf("line %d: malformed hunk header %q", start+1, header)
+	}
+	ranges := strings.Fields(header[3 : 3+end])
+	if len(ranges) != 2 || !strings.HasPrefix(ranges[0], "-") || !strings.HasPrefix(ranges[1], "+") {
+		return nil, 0, fmt.Errorf("line %d: malformed hunk header %q", start+1, header)
+	}
+	h := &patchHunk{header: header[:3+end+3]}
+	var err error
+	if h.oldStart, h.oldCount, err = parseHunkRange(ranges[0][1:]); err != nil {
+		return nil, 0, fmt.Errorf("line %d: %w", start+1, err)
+	}
+	if h.newStart, h.newCount, err = parseHunkRange(ranges[1][1:]); err != nil {
+		return nil, 0, fmt.Errorf("line %d: %w", start+1, err)
+	}
+
+	oldSeen, newSeen := 0, 0
+	i := start + 1
+	for ; i < len(lines) && (oldSeen < h.oldCount || newSeen < h.newCount); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "\\") {
+			markNoNewline(h)
+			continue
+		}
+		kind := byte(' ')
+		text := line
+		if line != "" {
+			kind, text = line[0], line[1:]
+		}
+		switch kind {
+		case ' ':
+			oldSeen++
+			newSeen++
+		case '-':
+			oldSeen++
+		case '+':
+			newSeen++
+		default:
+			return nil, 0, fmt.Errorf("line %d: unexpected line in hunk %s: %q", i+1, h.header, line)
+		}
+		h.lines = append(h.lines, patchLine{kind: kind, text: text + "\n"})
+	}
+	if oldSeen != h.oldCount || newSeen != h.newCount {
+		return nil, 0, fmt.Errorf("hunk %s is truncated: expected %d old/%d new lines, got %d/%d", h.header, h.oldCount, h.newCount, oldSeen, newSeen)
+	}
+	if i < len(lines) && strings.HasPrefix(lines[i], "\\") {
+		markNoNewline(h)
+		i++
+	}
+	return h, i, nil
+}
+
+// markNoNewline strips the trailing newline from the last line of h.
+func markNoNewline(h *patchHunk) {
+	if n := len(h.lines); n > 0 {
+		h.lines[n-1].text = strings.TrimSuffix(h.lines[n-1].text, "\n")
+	}
+}
+
+// parseHunkRange parses "start,count" or "start" (count 1).
+func parseHunkRange(s string) (int, int, error) {
+	startStr, countStr, hasCount := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad hunk range %q", s)
+	}
+	count := 1
+	if hasCount {
+		if count, err = strconv.Atoi(countStr); err != nil {
+			return 0, 0, fmt.Errorf("bad hunk range %q", s)
+		}
+	}
+	return start, count, nil
+}
+
+// splitGitDiffHeader splits "a/x b/y" from a diff --git line.
+func splitGitDiffHeader(s string) (string, string, bool) {
+	if idx := strings.Index(s, " b/"); idx >= 0 && strings.HasPrefix(s, "a/") {
+		return s[:idx], s[idx+1:], true
+	}
+	parts := strings.Fields(s)
+	if len(parts) == 2 {
+		return parts[0], parts[1], true
+	}
+	return "", "", false
+}
+
+// patchFileName extracts the file name from a ---/+++ header, dropping any timestamp after a tab.
+func patchFileName(s string) string {
+	name, _, _ := strings.Cut(s, "\t")
+	return strings.TrimSpace(name)
+}
+
+// resolvePatchPath strips git's a/ and b/ prefixes unless the prefixed path exists as given.
+func resolvePatchPath(p string) string {
+	if p == "" {
+		return ""
+	}
+	if strings.HasPrefix(p, "a/") || strings.HasPrefix(p, "b/") {
+		if _, err := os.Stat(p); err != nil {
+			p = p[2:]
+		}
+	}
+	return filepath.Clean(p)
+}
@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"testing"
+)
+
+// roundTrip diffs old against new, parses the diff and applies it to old, failing unless the
+// result is new.
+func roundTrip(t *testing.T, old, new string) {
+	t.Helper()
+	diff := unifiedDiff("f.txt", old, new, true, false)
+	patches, err := parsePatch(diff)
+	if err != nil {
+		t.Fatalf("parsing the diff of %q -> %q: %v\n%s", old, new, err, diff)
+	}
+	got, report, ok := applyHunks(old, patches[0].hunks)
+	if !ok || got != new {
+		t.Fatalf("%q -> %q: applied as %q (ok %v)\n%s\n%s", old, new, got, ok, diff, strings.Join(report, "\n"))
+	}
+	for _, line := range report {
+		if strings.Contains(line, "offset") || strings.Contains(line, "ignoring") {
+			t.Errorf("%q -> %q: hunk not applied where stated: %s", old, new, line)
+		}
+	}
+}
+
+func TestPatchRoundTrip(t *testing.T) {
+	tests := []struct{ name, old, new string }{
+		{"empty file gains lines", "", "a\nb\n"},
+		{"file emptied", "a\nb\n", ""},
+		{"no trailing newline", "a\nb", "a\nc"},
+		{"trailing newline added", "a\nb", "a\nb\n"},
+		{"trailing newline removed", "a\nb\n", "a\nb"},
+		{"CRLF", "a\r\nb\r\nc\r\n", "a\r\nB\r\nc\r\n"},
+		{"CRLF without trailing newline", "a\r\nb", "a\r\nb\r\nc"},
+		{"hunk at end of file", "1\n2\n3\n4\n5\n6\n7\n8\n", "1\n2\n3\n4\n5\n6\n7\n8\n9\n"},
+		{"hunk at start of file", "1\n2\n3\n4\n5\n6\n7\n8\n", "0\n1\n2\n3\n4\n5\n6\n7\n8\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) { roundTrip(t, tt.old, tt.new) })
+	}
+}
+
+// randomText builds up to 20 lines from a small alphabet, so diffs have repeated lines, with
+// mixed line endings and sometimes no final newline.
+func randomText(r *rand.Rand) string {
+	var b strings.Builder
+	n := r.IntN(20)
+	for i := 0; i < n; i++ {
+		b.WriteString([]string{"a", "b", "c", "", "  d"}[r.IntN(5)])
+		if i < n-1 || r.IntN(4) > 0 {
+			b.WriteString([]string{"\n", "\n", "\r\n"}[r.IntN(3)])
+		}
+	}
+	return b.String()
+}
+
+// mutate edits a random text by deleting, inserting and replacing lines.
+func mutate(r *rand.Rand, s string) string {
+	lines := splitLinesKeepEnds(s)
+	for edits := r.IntN(4); edits >= 0; edits-- {
+		i := r.IntN(len(lines) + 1)
+		switch r.IntN(3) {
+		case 0:
+			if i < len(lines) {
+				lines = append(lines[:i], lines[i+1:]...)
+			}
+		case 1:
+			lines = append(lines[:i], append([]string{randomText(r)}, lines[i:]...)...)
+		default:
+			if i < len(lines) {
+				lines[i] = randomText(r)
+			}
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+func TestPatchRoundTripRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 5000; i++ {
+		old := randomText(r)
+		new := mutate(r, old)
+		if old == new {
+			continue
+		}
+		roundTrip(t, old, new)
+	}
+}
+
+func TestApplyPatchKeepsCRLF(t *testing.T) {
+	t.Chdir(t.TempDir())
+	old, new := "one\r\ntwo\r\n", "one\r\n2\r\n"
+	if err := os.WriteFile("f.txt", []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	input, err := json.Marshal(ApplyPatchInput{Patch: unifiedDiff("f.txt", old, new, true, false)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ApplyPatch(input); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile("f.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != new {
+		t.Errorf("f.txt = %q, want %q", content, new)
+	}
+}
+
+func TestParsePatchConvertedToCRLF(t *testing.T) {
+	patch := strings.ReplaceAll("--- a/f.txt\n+++ b/f.txt\n@@ -1,1 +1,1 @@\n-a\n+b\n", "\n", "\r\n")
+	patches, err := parsePatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, ok := applyHunks("a\n", patches[0].hunks)
+	if !ok || got != "b\n" {
+		t.Errorf("applied as %q (ok %v), want %q", got, ok, "b\n")
+	}
+}
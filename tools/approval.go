@@ -0,0 +1,40 @@
+// Package tools provides the approval hook that guards tools with side effects beyond the working tree.
+package tools
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ApprovalRequest describes a guarded action a tool is about to perform.
+type ApprovalRequest struct {
+	Tool    string
+	Summary string
+	Risk    string // for shell commands, the risk level, e.g. "destructive"; empty otherwise
+}
+
+// ApprovalFunc decides whether a guarded action may proceed.
+type ApprovalFunc func(req ApprovalRequest) bool
+
+var (
+	approvalMu sync.Mutex
+	approve    ApprovalFunc = func(ApprovalRequest) bool { return true }
+)
+
+// SetApprovalFunc installs the approval policy consulted by guarded tools (e.g. git_commit).
+// The default policy approves everything.
+func SetApprovalFunc(fn ApprovalFunc) {
+	approvalMu.Lock()
+	defer approvalMu.Unlock()
+	approve = fn
+}
+
+// requireApproval asks the approval policy about req and returns an error if it is denied.
+func requireApproval(req ApprovalRequest) error {
+	approvalMu.Lock()
+	defer approvalMu.Unlock()
+	if !approve(req) {
+		return fmt.Errorf("%s: not approved by the user: %s", req.Tool, req.Summary)
+	}
+	return nil
+}
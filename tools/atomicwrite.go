@@ -0,0 +1,125 @@
+// Package tools provides the all-or-nothing multi-file writer used by multi-file editing tools.
+package tools
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// pendingWrite is one file change in an atomic batch: new content for path, or its removal.
+type pendingWrite struct {
+	path    string
+	content []byte
+	mode    fs.FileMode
+	remove  bool
+}
+
+// writeFilesAtomically applies every write in the batch or none of them. New contents are first
+// written to temp files next to their targets, then renamed into place; if a rename or removal
+// fails, the files already replaced are restored from their original contents and modes.
+// Directories created for new files are removed again on failure.
+func writeFilesAtomically(writes []pendingWrite) error {
+	type original struct {
+		existed bool
+		content []byte
+		mode    fs.FileMode
+	}
+	originals := make([]original, len(writes))
+	temps := make([]string, len(writes))
+	var created []string // directories made for new files, parents first
+	cleanup := func() {
+		for _, t := range temps {
+			if t != "" {
+				os.Remove(t)
+			}
+		}
+		for i := len(created) - 1; i >= 0; i-- {
+			os.Remove(created[i])
+		}
+	}
+
+	for i, w := range writes {
+		if info, err := os.Stat(w.path); err == nil {
+			content, err := os.ReadFile(w.path)
+			if err != nil {
+				cleanup()
+				return err
+			}
+			originals[i] = original{existed: true, content: content, mode: info.Mode().Perm()}
+		}
+		if w.remove {
+			continue
+		}
+		dir := filepath.Dir(w.path)
+		var missing []string
+		for d := dir; ; d = filepath.Dir(d) {
+			if _, err := os.Stat(d); !os.IsNotExist(err) || d == filepath.Dir(d) {
+				break
+			}
+			missing = append(missing, d)
+		}
+		for j := len(missing) - 1; j >= 0; j-- {
+			created = append(created, missing[j])
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			cleanup()
+			return fmt.Errorf("mkdir %s: %w", dir, err)
+		}
+		f, err := os.CreateTemp(dir, "."+filepath.Base(w.path)+".tmp-*")
+		if err != nil {
+			cleanup()
+			return err
+		}
+		temps[i] = f.Name()
+		mode := w.mode
+		if mode == 0 {
+			mode = 0644
+			if originals[i].existed {
+				mode = originals[i].mode
+			}
+		}
+		_, err = f.Write(w.content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Chmod(f.Name(), mode)
+		}
+		if err != nil {
+			cleanup()
+			return fmt.Errorf("write %s: %w", w.path, err)
+		}
+	}
+
+	for i, w := range writes {
+		var err error
+		if w.remove {
+			err = os.Remove(w.path)
+		} else {
+			err = os.Rename(temps[i], w.path)
+			if err == nil {
+				temps[i] = ""
+			}
+		}
+		if err == nil {
+			continue
+		}
+		// Roll back the files that were already replaced or removed.
+		for j := i - 1; j >= 0; j-- {
+			o := originals[j]
+			if o.existed {
+				// WriteFile only applies the mode to a file it creates, not to the one renamed over it.
+				if os.WriteFile(writes[j].path, o.content, o.mode) == nil {
+					os.Chmod(writes[j].path, o.mode)
+				}
+			} else {
+				os.Remove(writes[j].path)
+			}
+		}
+		cleanup()
+		return fmt.Errorf("%s: %w (no files were changed)", w.path, err)
+	}
+	return nil
+}
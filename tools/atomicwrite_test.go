@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFilesAtomicallyRollsBack(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("old.txt", []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	err := writeFilesAtomically([]pendingWrite{
+		{path: "old.txt", content: []byte("new\n"), mode: 0o644},
+		{path: filepath.Join("new", "sub", "file.txt"), content: []byte("x\n")},
+		{path: "missing.txt", remove: true}, // fails after the others were renamed into place
+	})
+	if err == nil {
+		t.Fatal("removing a missing file succeeded")
+	}
+	content, err := os.ReadFile("old.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "old\n" {
+		t.Errorf("old.txt = %q after rollback, want %q", content, "old\n")
+	}
+	info, err := os.Stat("old.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("old.txt mode after rollback = %v, want 0600", info.Mode().Perm())
+	}
+	if _, err := os.Stat("new"); !os.IsNotExist(err) {
+		t.Errorf("directory created for new/sub/file.txt was left behind: %v", err)
+	}
+}
@@ -0,0 +1,521 @@
+// Package tools provides the background process tools for the agent.
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProcessStartDefinition is the tool that starts a command in the background.
+var ProcessStartDefinition = ToolDefinition{
+	Name:        "process_start",
+	Description: "Start a long-running shell command (dev server, watcher, tail -f) in the background and return its id right away, with any output from the first moments. Use process_read to follow its output, process_send for input and process_stop to end it. Background processes are stopped when the session ends.",
+	InputSchema: ProcessStartInputSchema,
+	Function:    ProcessStart,
+}
+
+// ProcessReadDefinition is the tool that returns a background process's new output.
+var ProcessReadDefinition = ToolDefinition{
+	Name:        "process_read",
+	Description: "Return the output a background process has written since the last read (or all retained output with fromStart), optionally only lines matching a regex. waitSeconds waits for new (matching) output or exit, e.g. until a server logs that it is listening.",
+	InputSchema: ProcessReadInputSchema,
+	Function:    ProcessRead,
+}
+
+// ProcessStatusDefinition is the tool that reports whether background processes are running.
+var ProcessStatusDefinition = ToolDefinition{
+	Name:        "process_status",
+	Description: "Show the state of one background process, or all of them: running or exit code, pid, uptime, command, and how much output is unread.",
+	InputSchema: ProcessStatusInputSchema,
+	Function:    ProcessStatus,
+}
+
+// ProcessSendDefinition is the tool that writes to a background process's standard input.
+var ProcessSendDefinition = ToolDefinition{
+	Name:        "process_send",
+	Description: "Write text to a background process's standard input (a newline is appended unless noNewline), optionally closing stdin afterwards.",
+	InputSchema: ProcessSendInputSchema,
+	Function:    ProcessSend,
+}
+
+// ProcessStopDefinition is the tool that stops a background process.
+var ProcessStopDefinition = ToolDefinition{
+	Name:        "process_stop",
+	Description: "Stop a background process and its children (SIGTERM, then SIGKILL if it has not exited after a few seconds) and return its exit status and unread output.",
+	InputSchema: ProcessStopInputSchema,
+	Function:    ProcessStop,
+}
+
+// ProcessStartInput is the JSON shape for the process_start tool.
+type ProcessStartInput struct {
+	Command     string            `json:"command" jsonschema_description:"The shell command to run in the background (e.g. go run ./cmd/server)."`
+	WorkingDir  string            `json:"workingDir" jsonschema_description:"Optional working directory; default is the current directory."`
+	Env         map[string]string `json:"env" jsonschema_description:"Optional environment variables to set or override."`
+	WaitSeconds int               `json:"waitSeconds" jsonschema_description:"Seconds to collect initial output before returning; default 1, 0 uses the default, -1 returns immediately."`
+}
+
+// ProcessReadInput is the JSON shape for the process_read tool.
+type ProcessReadInput struct {
+	ID          string `json:"id" jsonschema_description:"Background process id returned by process_start (e.g. bg1)."`
+	Filter      string `json:"filter" jsonschema_description:"Optional RE2 regex; only matching lines are returned."`
+	FromStart   bool   `json:"fromStart" jsonschema_description:"If true, return all retained output instead of only new output."`
+	WaitSeconds int    `json:"waitSeconds" jsonschema_description:"Wait up to this many seconds (max 300) for new output (matching filter) or exit; default 0."`
+	MaxBytes    int    `json:"maxBytes" jsonschema_description:"Maximum bytes returned, keeping the first and last halves; default 16000."`
+}
+
+// ProcessStatusInput is the JSON shape for the process_status tool.
+type ProcessStatusInput struct {
+	ID string `json:"id" jsonschema_description:"Optional background process id; default lists all."`
+}
+
+// ProcessSendInput is the JSON shape for the process_send tool.
+type ProcessSendInput struct {
+	ID         string `json:"id" jsonschema_description:"Background process id."`
+	Text       string `json:"text" jsonschema_description:"Text to write to standard input."`
+	NoNewline  bool   `json:"noNewline" jsonschema_description:"If true, do not append a newline to text."`
+	CloseStdin bool   `json:"closeStdin" jsonschema_description:"If true, close standard input after writing (signals end of input)."`
+}
+
+// ProcessStopInput is the JSON shape for the process_stop tool.
+type ProcessStopInput struct {
+	ID string `json:"id" jsonschema_description:"Background process id."`
+}
+
+// ProcessStartInputSchema is the Anthropic tool input schema for process_start.
+var ProcessStartInputSchema = GenerateSchema[ProcessStartInput]()
+
+// ProcessReadInputSchema is the Anthropic tool input schema for process_read.
+var ProcessReadInputSchema = GenerateSchema[ProcessReadInput]()
+
+// ProcessStatusInputSchema is the Anthropic tool input schema for process_status.
+var ProcessStatusInputSchema = GenerateSchema[ProcessStatusInput]()
+
+// ProcessSendInputSchema is the Anthropic tool input schema for process_send.
+var ProcessSendInputSchema = GenerateSchema[ProcessSendInput]()
+
+// ProcessStopInputSchema is the Anthropic tool input schema for process_stop.
+var ProcessStopInputSchema = GenerateSchema[ProcessStopInput]()
+
+const (
+	maxBackgroundProcesses = 8
+	backgroundLogBytes     = 1 << 20 // output retained per process; older output is dropped
+	defaultProcessWait     = time.Second
+	maxProcessReadWait     = 5 * time.Minute
+	processStopGrace       = 3 * time.Second
+	processSendTimeout     = 5 * time.Second
+)
+
+// backgroundProcess is a command started by process_start. Its stdout and stderr are interleaved
+// into one log; readOffset and the log's base are absolute byte offsets into everything written.
+type backgroundProcess struct {
+	id      string
+	command string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	started time.Time
+	done    chan struct{} // closed once the process has exited and its output is drained
+
+	mu         sync.Mutex
+	log        []byte
+	base       int
+	readOffset int
+	changed    chan struct{} // closed and replaced on every write
+	exitCode   int
+	exitSignal string // set instead of exitCode when a signal ended the process
+	ended      time.Time
+}
+
+var (
+	backgroundMu    sync.Mutex
+	backgroundProcs = map[string]*backgroundProcess{}
+	backgroundSeq   int
+)
+
+func (p *backgroundProcess) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.log = append(p.log, b...)
+	// Trim in batches so a chatty process doesn't shift the whole log on every write.
+	if len(p.log) > 2*backgroundLogBytes {
+		drop := len(p.log) - backgroundLogBytes
+		p.log = append(p.log[:0], p.log[drop:]...)
+		p.base += drop
+	}
+	close(p.changed)
+	p.changed = make(chan struct{})
+	return len(b), nil
+}
+
+// exited reports whether the process has finished.
+func (p *backgroundProcess) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// state renders e.g. "running, pid 4242, up 12s", "exited with code 1 after 3s" or "killed after 5s".
+func (p *backgroundProcess) state() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.exited() {
+		return fmt.Sprintf("running, pid %d, up %s", p.cmd.Process.Pid, time.Since(p.started).Round(time.Second))
+	}
+	ran := p.ended.Sub(p.started).Round(time.Millisecond)
+	if p.exitSignal != "" {
+		return fmt.Sprintf("%s after %s", p.exitSignal, ran)
+	}
+	return fmt.Sprintf("exited with code %d after %s", p.exitCode, ran)
+}
+
+// unread returns the output since the last read (or all retained output when fromStart), how many
+// bytes were dropped from the log before it could be read, and marks everything as read.
+func (p *backgroundProcess) unread(fromStart bool) (string, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	from := p.readOffset
+	if fromStart {
+		from = p.base
+	}
+	dropped := 0
+	if from < p.base {
+		dropped, from = p.base-from, p.base
+	}
+	p.readOffset = p.base + len(p.log)
+	return string(p.log[from-p.base:]), dropped
+}
+
+// waitForOutput blocks until the process writes output after the last read that has a line matching
+// filter (any output when filter is nil), the process exits, or timeout passes.
+func (p *backgroundProcess) waitForOutput(filter *regexp.Regexp, timeout time.Duration) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		p.mu.Lock()
+		pending := p.log[max(p.readOffset-p.base, 0):]
+		ready := len(pending) > 0 && (filter == nil || filter.Match(pending))
+		changed := p.changed
+		p.mu.Unlock()
+		if ready || p.exited() {
+			return
+		}
+		select {
+		case <-changed:
+		case <-p.done:
+		case <-deadline.C:
+			return
+		}
+	}
+}
+
+// lookupProcess returns the background process with the given id.
+func lookupProcess(tool, id string) (*backgroundProcess, error) {
+	backgroundMu.Lock()
+	defer backgroundMu.Unlock()
+	p, ok := backgroundProcs[strings.TrimSpace(id)]
+	if !ok {
+		if len(backgroundProcs) == 0 {
+			return nil, fmt.Errorf("%s: no background process %q; none have been started", tool, id)
+		}
+		return nil, fmt.Errorf("%s: no background process %q (see process_status)", tool, id)
+	}
+	return p, nil
+}
+
+// ProcessStart implements the process_start tool.
+func ProcessStart(input json.RawMessage) (string, error) {
+	var startInput ProcessStartInput
+	if err := json.Unmarshal(input, &startInput); err != nil {
+		return "", fmt.Errorf("process_start input: %w", err)
+	}
+	command := strings.TrimSpace(startInput.Command)
+	if command == "" {
+		return "", fmt.Errorf("process_start: command is required")
+	}
+	backgroundMu.Lock()
+	running := 0
+	for _, p := range backgroundProcs {
+		if !p.exited() {
+			running++
+		}
+	}
+	backgroundMu.Unlock()
+	if running >= maxBackgroundProcesses {
+		return "", fmt.Errorf("process_start: %d background processes are already running; stop one first", running)
+	}
+	if _, err := checkCommandRisk("process_start", command, startInput.WorkingDir); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = startInput.WorkingDir
+	cmd.Env = commandEnv(startInput.Env)
+	startInProcessGroup(cmd)
+	if err := sandboxCommand(cmd); err != nil {
+		return "", fmt.Errorf("process_start: %w", err)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", fmt.Errorf("process_start: %w", err)
+	}
+	p := &backgroundProcess{command: command, cmd: cmd, stdin: stdin, done: make(chan struct{}), changed: make(chan struct{})}
+	cmd.Stdout, cmd.Stderr = p, p
+	// Children that outlive the shell can hold the output pipes open; stop waiting for them.
+	cmd.WaitDelay = commandKillGracePeriod
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("process_start: %w", err)
+	}
+	p.started = time.Now()
+	go func() {
+		if errors.Is(cmd.Wait(), exec.ErrWaitDelay) {
+			// The shell exited but left children holding its output open: stop them, as it has ended.
+			killProcessGroup(cmd)
+		}
+		p.mu.Lock()
+		p.exitCode = cmd.ProcessState.ExitCode()
+		if p.exitCode == -1 {
+			p.exitSignal = strings.TrimPrefix(cmd.ProcessState.String(), "signal: ")
+		}
+		p.ended = time.Now()
+		p.mu.Unlock()
+		close(p.done)
+	}()
+
+	backgroundMu.Lock()
+	backgroundSeq++
+	p.id = "bg" + strconv.Itoa(backgroundSeq)
+	backgroundProcs[p.id] = p
+	backgroundMu.Unlock()
+
+	wait := defaultProcessWait
+	if startInput.WaitSeconds > 0 {
+		wait = min(time.Duration(startInput.WaitSeconds)*time.Second, maxProcessReadWait)
+	}
+	if startInput.WaitSeconds >= 0 {
+		select {
+		case <-p.done:
+		case <-time.After(wait):
+		}
+	}
+	output, _ := p.unread(false)
+	result := fmt.Sprintf("Started %s (%s): %s", p.id, p.state(), command)
+	if output != "" {
+		result += "\noutput:\n" + boundOutput(output, defaultCommandOutput)
+	}
+	return result, nil
+}
+
+// ProcessRead implements the process_read tool.
+func ProcessRead(input json.RawMessage) (string, error) {
+	var readInput ProcessReadInput
+	if err := json.Unmarshal(input, &readInput); err != nil {
+		return "", fmt.Errorf("process_read input: %w", err)
+	}
+	p, err := lookupProcess("process_read", readInput.ID)
+	if err != nil {
+		return "", err
+	}
+	var filter *regexp.Regexp
+	if readInput.Filter != "" {
+		filter, err = regexp.Compile("(?m)" + readInput.Filter)
+		if err != nil {
+			return "", fmt.Errorf("process_read: invalid filter: %w", err)
+		}
+	}
+	if readInput.WaitSeconds > 0 && !readInput.FromStart {
+		p.waitForOutput(filter, min(time.Duration(readInput.WaitSeconds)*time.Second, maxProcessReadWait))
+	}
+	limit := readInput.MaxBytes
+	if limit <= 0 {
+		limit = defaultCommandOutput
+	}
+	output, dropped := p.unread(readInput.FromStart)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s)\n", p.id, p.state())
+	if dropped > 0 {
+		fmt.Fprintf(&b, "[%s of older output was dropped]\n", formatSize(int64(dropped)))
+	}
+	if filter != nil && output != "" {
+		lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+		var matched []string
+		for _, line := range lines {
+			if filter.MatchString(line) {
+				matched = append(matched, line)
+			}
+		}
+		fmt.Fprintf(&b, "%d of %s match %s\n", len(matched), countLabel(len(lines), "line"), readInput.Filter)
+		output = ""
+		if len(matched) > 0 {
+			output = strings.Join(matched, "\n") + "\n"
+		}
+	}
+	if output == "" {
+		if filter == nil {
+			b.WriteString("(no new output)")
+		}
+		return strings.TrimSuffix(b.String(), "\n"), nil
+	}
+	b.WriteString(boundOutput(output, limit))
+	return b.String(), nil
+}
+
+// ProcessStatus implements the process_status tool.
+func ProcessStatus(input json.RawMessage) (string, error) {
+	var statusInput ProcessStatusInput
+	if err := json.Unmarshal(input, &statusInput); err != nil {
+		return "", fmt.Errorf("process_status input: %w", err)
+	}
+	var procs []*backgroundProcess
+	if statusInput.ID != "" {
+		p, err := lookupProcess("process_status", statusInput.ID)
+		if err != nil {
+			return "", err
+		}
+		procs = append(procs, p)
+	} else {
+		backgroundMu.Lock()
+		for _, p := range backgroundProcs {
+			procs = append(procs, p)
+		}
+		backgroundMu.Unlock()
+		if len(procs) == 0 {
+			return "No background processes.", nil
+		}
+		sort.Slice(procs, func(i, j int) bool { return procs[i].started.Before(procs[j].started) })
+	}
+	var lines []string
+	for _, p := range procs {
+		p.mu.Lock()
+		total := p.base + len(p.log)
+		unread := total - p.readOffset
+		p.mu.Unlock()
+		lines = append(lines, fmt.Sprintf("%s  %s  output %s (%s unread)  %s",
+			p.id, p.state(), formatSize(int64(total)), formatSize(int64(unread)), p.command))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// ProcessSend implements the process_send tool.
+func ProcessSend(input json.RawMessage) (string, error) {
+	var sendInput ProcessSendInput
+	if err := json.Unmarshal(input, &sendInput); err != nil {
+		return "", fmt.Errorf("process_send input: %w", err)
+	}
+	p, err := lookupProcess("process_send", sendInput.ID)
+	if err != nil {
+		return "", err
+	}
+	if p.exited() {
+		return "", fmt.Errorf("process_send: %s has %s", p.id, p.state())
+	}
+	text := sendInput.Text
+	if !sendInput.NoNewline {
+		text += "\n"
+	}
+	// A process that does not read its input eventually fills the pipe; don't block on it forever.
+	written := make(chan error, 1)
+	go func() {
+		_, err := io.WriteString(p.stdin, text)
+		if err == nil && sendInput.CloseStdin {
+			err = p.stdin.Close()
+		}
+		written <- err
+	}()
+	select {
+	case err := <-written:
+		if err != nil {
+			return "", fmt.Errorf("process_send: %s: %w", p.id, err)
+		}
+	case <-time.After(processSendTimeout):
+		return "", fmt.Errorf("process_send: %s is not reading its input", p.id)
+	}
+	result := fmt.Sprintf("Wrote %s to %s.", formatSize(int64(len(text))), p.id)
+	if sendInput.CloseStdin {
+		result += " Closed its stdin."
+	}
+	return result, nil
+}
+
+// ProcessStop implements the process_stop tool.
+func ProcessStop(input json.RawMessage) (string, error) {
+	var stopInput ProcessStopInput
+	if err := json.Unmarshal(input, &stopInput); err != nil {
+		return "", fmt.Errorf("process_stop input: %w", err)
+	}
+	p, err := lookupProcess("process_stop", stopInput.ID)
+	if err != nil {
+		return "", err
+	}
+	if p.exited() {
+		return fmt.Sprintf("%s had already %s.", p.id, p.state()), nil
+	}
+	killed := stopProcess(p)
+	output, _ := p.unread(false)
+	result := fmt.Sprintf("Stopped %s (%s)", p.id, p.state())
+	if killed {
+		result += "; it ignored SIGTERM and was killed"
+	}
+	if output != "" {
+		result += "\nunread output:\n" + boundOutput(output, defaultCommandOutput)
+	}
+	return result, nil
+}
+
+// stopProcess terminates p's process group, killing it if it is still running after the grace
+// period, and waits for it to exit. It reports whether SIGKILL was needed.
+func stopProcess(p *backgroundProcess) bool {
+	terminateProcessGroup(p.cmd)
+	select {
+	case <-p.done:
+		return false
+	case <-time.After(processStopGrace):
+	}
+	killProcessGroup(p.cmd)
+	select {
+	case <-p.done:
+	case <-time.After(processStopGrace):
+	}
+	return true
+}
+
+// StopBackgroundProcesses stops every running background process and the persistent shell; call it
+// when the session ends.
+func StopBackgroundProcesses() {
+	closeShell()
+	backgroundMu.Lock()
+	var running []*backgroundProcess
+	for _, p := range backgroundProcs {
+		if !p.exited() {
+			running = append(running, p)
+		}
+	}
+	backgroundMu.Unlock()
+	var wg sync.WaitGroup
+	for _, p := range running {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopProcess(p)
+		}()
+	}
+	wg.Wait()
+}
+
+// boundOutput bounds s to limit bytes the way runCommand bounds output.
+func boundOutput(s string, limit int) string {
+	b := newHeadTailBuffer(limit)
+	io.WriteString(b, s)
+	return b.String()
+}
@@ -0,0 +1,360 @@
+// Package tools provides the per-session checkpoint store used to undo agent file changes.
+package tools
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Checkpoints is the session checkpoint store. Mutating tools snapshot the paths they are about
+// to change into it; the CLI groups snapshots per user turn and offers /undo and /restore.
+var Checkpoints = NewCheckpointStore()
+
+// pathSnapshot records the state of one path before the first change made to it within a checkpoint.
+type pathSnapshot struct {
+	existed bool
+	isDir   bool
+	mode    fs.FileMode
+	content []byte
+	link    string // target, when the path was a symbolic link
+}
+
+// Checkpoint is the set of pre-change snapshots taken during one user turn.
+type Checkpoint struct {
+	ID      int
+	Label   string
+	Created time.Time
+	order   []string
+	files   map[string]pathSnapshot
+}
+
+// Paths returns the paths captured by the checkpoint, relative to the working directory when possible.
+func (c *Checkpoint) Paths() []string {
+	paths := make([]string, 0, len(c.order))
+	for _, p := range c.order {
+		paths = append(paths, displayPath(p))
+	}
+	return paths
+}
+
+// CheckpointStore keeps an ordered list of checkpoints for the current session.
+type CheckpointStore struct {
+	mu           sync.Mutex
+	checkpoints  []*Checkpoint
+	current      *Checkpoint
+	pendingLabel string
+	turnOpen     bool
+	nextID       int
+}
+
+// NewCheckpointStore returns an empty checkpoint store.
+func NewCheckpointStore() *CheckpointStore {
+	return &CheckpointStore{nextID: 1}
+}
+
+// BeginTurn starts a new checkpoint group labelled with the user message. The checkpoint is only
+// recorded once a tool snapshots something, so turns without file changes do not show up.
+func (s *CheckpointStore) BeginTurn(label string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = nil
+	s.pendingLabel = label
+	s.turnOpen = true
+}
+
+// Snapshot records the current state of each path (and, for directories, everything below it)
+// unless the path was already captured in the current checkpoint.
+func (s *CheckpointStore) Snapshot(paths ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		label := s.pendingLabel
+		if !s.turnOpen {
+			label = "(outside a user turn)"
+		}
+		s.current = &Checkpoint{ID: s.nextID, Label: label, Created: time.Now(), files: map[string]pathSnapshot{}}
+		s.nextID++
+		s.checkpoints = append(s.checkpoints, s.current)
+	}
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return fmt.Errorf("checkpoint: %w", err)
+		}
+		if err := s.capture(abs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// capture snapshots abs into the current checkpoint, walking directories recursively.
+func (s *CheckpointStore) capture(abs string) error {
+	if _, ok := s.current.files[abs]; ok {
+		return nil
+	}
+	info, err := os.Lstat(abs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.record(abs, pathSnapshot{})
+			return nil
+		}
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if !info.IsDir() {
+		snap, err := fileSnapshot(abs, info.Mode())
+		if err != nil {
+			return err
+		}
+		s.record(abs, snap)
+		return nil
+	}
+	return filepath.WalkDir(abs, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return fmt.Errorf("checkpoint: %w", walkErr)
+		}
+		if _, ok := s.current.files[path]; ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("checkpoint: %w", err)
+		}
+		if d.IsDir() {
+			s.record(path, pathSnapshot{existed: true, isDir: true, mode: info.Mode().Perm()})
+			return nil
+		}
+		snap, err := fileSnapshot(path, info.Mode())
+		if err != nil {
+			return err
+		}
+		s.record(path, snap)
+		return nil
+	})
+}
+
+// fileSnapshot captures a file, or a symbolic link as the link itself rather than its target's content.
+func fileSnapshot(path string, mode fs.FileMode) (pathSnapshot, error) {
+	if mode&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return pathSnapshot{}, fmt.Errorf("checkpoint: %w", err)
+		}
+		return pathSnapshot{existed: true, link: target}, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return pathSnapshot{}, fmt.Errorf("checkpoint: %w", err)
+	}
+	return pathSnapshot{existed: true, mode: mode.Perm(), content: content}, nil
+}
+
+func (s *CheckpointStore) record(abs string, snap pathSnapshot) {
+	s.current.files[abs] = snap
+	s.current.order = append(s.current.order, abs)
+}
+
+// List returns the recorded checkpoints, oldest first.
+func (s *CheckpointStore) List() []*Checkpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Checkpoint{}, s.checkpoints...)
+}
+
+// Touched returns every path snapshotted during the session, relative to the working directory when possible.
+func (s *CheckpointStore) Touched() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := map[string]bool{}
+	var paths []string
+	for _, c := range s.checkpoints {
+		for _, p := range c.order {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, displayPath(p))
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Undo reverts the most recent checkpoint.
+func (s *CheckpointStore) Undo() (string, error) {
+	s.mu.Lock()
+	n := len(s.checkpoints)
+	var id int
+	if n > 0 {
+		id = s.checkpoints[n-1].ID
+	}
+	s.mu.Unlock()
+	if n == 0 {
+		return "", fmt.Errorf("undo: no checkpoints to revert")
+	}
+	return s.Restore(id)
+}
+
+// Restore rolls the working tree back to the state before checkpoint id, reverting it and every
+// later checkpoint (newest first), then drops them from the store.
+func (s *CheckpointStore) Restore(id int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx := -1
+	for i, c := range s.checkpoints {
+		if c.ID == id {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return "", fmt.Errorf("restore: no checkpoint #%d", id)
+	}
+	restored := map[string]bool{}
+	for i := len(s.checkpoints) - 1; i >= idx; i-- {
+		if err := restoreCheckpoint(s.checkpoints[i]); err != nil {
+			s.checkpoints = s.checkpoints[:i+1]
+			s.current = nil
+			return "", fmt.Errorf("restore #%d: %w", s.checkpoints[i].ID, err)
+		}
+		for _, p := range s.checkpoints[i].order {
+			restored[p] = true
+		}
+	}
+	count := len(s.checkpoints) - idx
+	s.checkpoints = s.checkpoints[:idx]
+	s.current = nil
+	return fmt.Sprintf("Reverted %d checkpoint(s), restored %d path(s) to their state before #%d.", count, len(restored), id), nil
+}
+
+// restoreCheckpoint writes back every snapshot in c: directories first (shallowest first), then files,
+// then removes paths that did not exist (deepest first).
+func restoreCheckpoint(c *Checkpoint) error {
+	var dirs, files, absent []string
+	for _, p := range c.order {
+		snap := c.files[p]
+		switch {
+		case !snap.existed:
+			absent = append(absent, p)
+		case snap.isDir:
+			dirs = append(dirs, p)
+		default:
+			files = append(files, p)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) < len(dirs[j]) })
+	sort.Slice(absent, func(i, j int) bool { return len(absent[i]) > len(absent[j]) })
+
+	for _, p := range dirs {
+		if info, err := os.Lstat(p); err == nil && !info.IsDir() {
+			if err := os.Remove(p); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(p, c.files[p].mode); err != nil {
+			return err
+		}
+		if err := os.Chmod(p, c.files[p].mode); err != nil {
+			return err
+		}
+	}
+	for _, p := range files {
+		snap := c.files[p]
+		if info, err := os.Lstat(p); err == nil && info.IsDir() {
+			if err := os.RemoveAll(p); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		if snap.link != "" {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err := os.Symlink(snap.link, p); err != nil {
+				return err
+			}
+			continue
+		}
+		if info, err := os.Lstat(p); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			// A link put in place of the file would have the content written through it.
+			if err := os.Remove(p); err != nil {
+				return err
+			}
+		}
+		if err := os.WriteFile(p, snap.content, snap.mode); err != nil {
+			return err
+		}
+		if err := os.Chmod(p, snap.mode); err != nil {
+			return err
+		}
+	}
+	for _, p := range absent {
+		info, err := os.Lstat(p)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			// Only remove directories that are now empty; anything left inside was not created by the agent.
+			removeEmptyDirs(p)
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeEmptyDirs removes dir and its subdirectories, bottom-up, as long as they contain no files.
+func removeEmptyDirs(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			removeEmptyDirs(filepath.Join(dir, e.Name()))
+		}
+	}
+	_ = os.Remove(dir)
+}
+
+// displayPath returns abs relative to the working directory when it lies inside it.
+func displayPath(abs string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return abs
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return abs
+	}
+	return rel
+}
+
+// snapshotForWrite snapshots each path plus its highest missing ancestor directory, so undo also
+// removes parent directories that the write is about to create.
+func snapshotForWrite(paths ...string) error {
+	var targets []string
+	for _, p := range paths {
+		missing := ""
+		for dir := filepath.Dir(filepath.Clean(p)); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+			if _, err := os.Lstat(dir); err == nil {
+				break
+			}
+			missing = dir
+		}
+		if missing != "" {
+			targets = append(targets, missing)
+		}
+		targets = append(targets, p)
+	}
+	return Checkpoints.Snapshot(targets...)
+}
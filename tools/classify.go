@@ -0,0 +1,207 @@
+// Package tools provides the content classifier used to keep binary and oversized files out of tool results.
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// classifySampleBytes is how much of a file is inspected to decide whether it is text.
+const classifySampleBytes = 8 * 1024
+
+// maxInvalidUTF8Ratio is the share of invalid UTF-8 bytes above which a file counts as binary.
+const maxInvalidUTF8Ratio = 0.3
+
+// contentInfo describes a file's size and whether its content is text, and in which encoding.
+type contentInfo struct {
+	size     int64
+	binary   bool
+	encoding string // ascii, utf-8, utf-8-bom, utf-16le, utf-16be, or unknown-8bit
+	reason   string // why the content is considered binary
+}
+
+// String summarizes the classification, e.g. "text utf-8, 4.2 KB" or "binary (NUL bytes), 12.0 MB".
+func (c contentInfo) String() string {
+	if c.binary {
+		return fmt.Sprintf("binary (%s), %s", c.reason, formatSize(c.size))
+	}
+	return fmt.Sprintf("text %s, %s", c.encoding, formatSize(c.size))
+}
+
+// classifyFile inspects the start of the file at path.
+func classifyFile(path string) (contentInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return contentInfo{}, err
+	}
+	if info.IsDir() {
+		return contentInfo{}, fmt.Errorf("%s is a directory", path)
+	}
+	sample := make([]byte, classifySampleBytes)
+	n, err := io.ReadFull(f, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return contentInfo{}, err
+	}
+	c := classifyBytes(sample[:n], n == classifySampleBytes)
+	c.size = info.Size()
+	return c, nil
+}
+
+// classifyBytes classifies a content sample. truncated means the sample was cut from a longer
+// file, so an incomplete rune at its end is not counted as invalid.
+func classifyBytes(sample []byte, truncated bool) contentInfo {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}):
+		return contentInfo{encoding: "utf-8-bom"}
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return contentInfo{encoding: "utf-16le"}
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return contentInfo{encoding: "utf-16be"}
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return contentInfo{binary: true, reason: "NUL bytes"}
+	}
+	if truncated {
+		// Drop a rune split by the sample boundary.
+		for i := 0; i < utf8.UTFMax && len(sample) > 0 && !utf8.Valid(sample); i++ {
+			sample = sample[:len(sample)-1]
+		}
+	}
+	ascii := true
+	invalid := 0
+	for i := 0; i < len(sample); {
+		if sample[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		ascii = false
+		r, size := utf8.DecodeRune(sample[i:])
+		if r == utf8.RuneError && size <= 1 {
+			invalid++
+		}
+		i += size
+	}
+	switch {
+	case ascii:
+		return contentInfo{encoding: "ascii"}
+	case invalid == 0:
+		return contentInfo{encoding: "utf-8"}
+	case float64(invalid)/float64(len(sample)) > maxInvalidUTF8Ratio:
+		return contentInfo{binary: true, reason: "mostly invalid UTF-8"}
+	default:
+		return contentInfo{encoding: "unknown-8bit"}
+	}
+}
+
+// decodeText converts content in the given encoding to UTF-8, dropping byte order marks.
+func decodeText(content []byte, encoding string) string {
+	switch encoding {
+	case "utf-8-bom":
+		return string(content[3:])
+	case "utf-16le", "utf-16be":
+		content = content[2:]
+		units := make([]uint16, len(content)/2)
+		for i := range units {
+			if encoding == "utf-16le" {
+				units[i] = uint16(content[2*i]) | uint16(content[2*i+1])<<8
+			} else {
+				units[i] = uint16(content[2*i])<<8 | uint16(content[2*i+1])
+			}
+		}
+		return string(utf16.Decode(units))
+	}
+	return string(content)
+}
+
+// formatSize renders a byte count as B, KB, MB or GB.
+func formatSize(n int64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	case n < 1024*1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	default:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1024*1024*1024))
+	}
+}
+
+// readText reads the file at path as text, decoding BOM-marked UTF-8 and UTF-16. For binary files
+// the returned text is empty and info.binary is set.
+func readText(path string) (string, contentInfo, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", contentInfo{}, err
+	}
+	sample := content
+	if len(sample) > classifySampleBytes {
+		sample = sample[:classifySampleBytes]
+	}
+	info := classifyBytes(sample, len(content) > classifySampleBytes)
+	info.size = int64(len(content))
+	if info.binary {
+		return "", info, nil
+	}
+	return decodeText(content, info.encoding), info, nil
+}
+
+// readTextPreview returns up to maxLines leading lines of the text file at path, decoded like
+// readText, without reading more than maxBytes into memory; the rest of the file is only scanned
+// to count its lines. A line cut off by maxBytes ends in "…".
+func readTextPreview(path, encoding string, maxLines, maxBytes int) ([]string, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	buf := make([]byte, maxBytes&^1) // even, so UTF-16 code units are never split
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, 0, err
+	}
+	head := buf[:n]
+	newlines := countNewlines(head, encoding)
+	lines := strings.Split(decodeText(head, encoding), "\n")
+	switch {
+	case len(lines) > maxLines:
+		lines = lines[:maxLines]
+	case n == len(buf) && len(lines) > 1:
+		lines = lines[:len(lines)-1] // the last line continues past maxBytes
+	case n == len(buf):
+		lines[0] = strings.ToValidUTF8(lines[0], "") + "…"
+	}
+	for err == nil {
+		n, err = io.ReadFull(f, buf)
+		newlines += countNewlines(buf[:n], encoding)
+	}
+	if err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, 0, err
+	}
+	return lines, newlines + 1, nil
+}
+
+// countNewlines counts the line feeds in content in the given encoding. For UTF-16, content must
+// start on a code unit boundary.
+func countNewlines(content []byte, encoding string) int {
+	if encoding != "utf-16le" && encoding != "utf-16be" {
+		return bytes.Count(content, []byte{'\n'})
+	}
+	count := 0
+	for i := 0; i+1 < len(content); i += 2 {
+		if encoding == "utf-16le" && content[i] == '\n' && content[i+1] == 0 || encoding == "utf-16be" && content[i] == 0 && content[i+1] == '\n' {
+			count++
+		}
+	}
+	return count
+}
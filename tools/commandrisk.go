@@ -0,0 +1,874 @@
+// Package tools provides the shell command risk classifier used by the command tools.
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RiskLevel classifies what a shell command may do, from harmless to most dangerous.
+type RiskLevel int
+
+const (
+	RiskReadOnly    RiskLevel = iota // only reads files or prints information
+	RiskWorkspace                    // creates or changes files in the workspace, or runs project programs
+	RiskNetwork                      // talks to the network
+	RiskDestructive                  // deletes data, rewrites history, kills processes or writes outside the workspace
+	RiskPrivileged                   // acts with elevated privileges or runs downloaded code
+)
+
+// String returns the level's name as used in events and approval prompts.
+func (r RiskLevel) String() string {
+	switch r {
+	case RiskReadOnly:
+		return "read-only"
+	case RiskWorkspace:
+		return "workspace-modifying"
+	case RiskNetwork:
+		return "networked"
+	case RiskDestructive:
+		return "destructive"
+	default:
+		return "privileged"
+	}
+}
+
+// CommandRisk is the classification of a shell command string: the overall level, the highest of
+// its sub-commands', and one entry per sub-command that is not read-only.
+type CommandRisk struct {
+	Level   RiskLevel
+	Reasons []string // e.g. "rm -rf build: destructive (deletes files)"
+}
+
+// Summary renders the risk for display, e.g. "destructive: rm -rf build (deletes files)".
+func (r CommandRisk) Summary() string {
+	if len(r.Reasons) == 0 {
+		return r.Level.String()
+	}
+	return r.Level.String() + ": " + strings.Join(r.Reasons, "; ")
+}
+
+// checkCommandRisk classifies a command a tool is about to run, reports it as a command event and,
+// when the risk calls for it, asks the approval policy, returning an error if it is denied.
+func checkCommandRisk(tool, command, dir string) (CommandRisk, error) {
+	risk := ClassifyCommand(command, dir)
+	emitEvent(Event{Type: EventCommand, Tool: tool, Command: command, Risk: risk.Level.String(), Reasons: risk.Reasons})
+	if risk.needsApproval() {
+		summary := fmt.Sprintf("run %q (%s)", command, strings.Join(risk.Reasons, "; "))
+		if err := requireApproval(ApprovalRequest{Tool: tool, Summary: summary, Risk: risk.Level.String()}); err != nil {
+			return risk, err
+		}
+	}
+	return risk, nil
+}
+
+// needsApproval reports whether a command at this level must be approved before it runs.
+// Reading and changing the workspace is what the agent is for; anything beyond that is asked about.
+func (r CommandRisk) needsApproval() bool {
+	return r.Level >= RiskNetwork
+}
+
+// shellCommand is one simple command: its words (quotes removed) and output redirection targets.
+type shellCommand struct {
+	words       []string
+	writes      []string // files written by >, >>, &> and similar
+	substituted []string // contents of <(...) and >(...) arguments
+	display     string   // the words joined, for reasons
+}
+
+// shellParser splits a shell command string into pipelines of simple commands. It understands
+// quoting, ; & && || | and newlines, ( ) subshells, $( ) and ` ` substitutions and <( ) and >( )
+// process substitutions (whose contents become pipelines of their own), redirections, comments
+// and here-documents. It does not expand
+// anything, which is enough to recognise which programs run and which files are written.
+type shellParser struct {
+	src       string
+	pos       int
+	pipelines [][]shellCommand
+	heredocs  []string // delimiters whose bodies start at the next newline
+}
+
+// parseShell returns the pipelines in src, including those nested in subshells and substitutions.
+func parseShell(src string) [][]shellCommand {
+	p := &shellParser{src: src}
+	p.parse()
+	return p.pipelines
+}
+
+func (p *shellParser) parse() {
+	var pipeline []shellCommand
+	var cmd shellCommand
+	var word strings.Builder
+	inWord := false
+	endWord := func() {
+		if inWord {
+			cmd.words = append(cmd.words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(cmd.words) > 0 || len(cmd.writes) > 0 {
+			cmd.display = strings.Join(cmd.words, " ")
+			pipeline = append(pipeline, cmd)
+		}
+		cmd = shellCommand{}
+	}
+	endPipeline := func() {
+		endCommand()
+		if len(pipeline) > 0 {
+			p.pipelines = append(p.pipelines, pipeline)
+		}
+		pipeline = nil
+	}
+
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == ' ' || c == '\t':
+			endWord()
+			p.pos++
+		case c == '\n':
+			endPipeline()
+			p.pos++
+			p.skipHeredocs()
+		case c == ';' || c == '&' || c == '|':
+			op := p.operator()
+			if op == "|" || op == "|&" {
+				endCommand()
+			} else if strings.HasPrefix(op, "&>") {
+				endWord()
+				p.redirect(&cmd, true)
+			} else {
+				endPipeline()
+			}
+		case (c == '<' || c == '>') && strings.HasPrefix(p.src[p.pos+1:], "("):
+			endWord()
+			p.pos++
+			inner := p.enclosed('(', ')')
+			p.pipelines = append(p.pipelines, parseShell(inner)...)
+			cmd.words = append(cmd.words, string(c)+"(...)")
+			cmd.substituted = append(cmd.substituted, inner)
+		case c == '>' || c == '<':
+			// A word made only of digits right before the operator is a file descriptor (2>file).
+			if inWord && strings.Trim(word.String(), "0123456789") == "" {
+				word.Reset()
+				inWord = false
+			}
+			endWord()
+			p.redirect(&cmd, c == '>')
+		case c == '#' && !inWord:
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == '(' && !inWord:
+			endPipeline()
+			inner := p.enclosed('(', ')')
+			p.pipelines = append(p.pipelines, parseShell(inner)...)
+		case c == ')':
+			p.pos++
+		case c == '\\':
+			if p.pos+1 < len(p.src) && p.src[p.pos+1] != '\n' {
+				word.WriteByte(p.src[p.pos+1])
+				inWord = true
+			}
+			p.pos += 2
+		case c == '\'':
+			end := strings.IndexByte(p.src[p.pos+1:], '\'')
+			if end < 0 {
+				end = len(p.src) - p.pos - 1
+			}
+			word.WriteString(p.src[p.pos+1 : p.pos+1+end])
+			inWord = true
+			p.pos += end + 2
+		case c == '"':
+			p.pos++
+			for p.pos < len(p.src) && p.src[p.pos] != '"' {
+				switch {
+				case p.src[p.pos] == '\\' && p.pos+1 < len(p.src):
+					word.WriteByte(p.src[p.pos+1])
+					p.pos += 2
+				case p.src[p.pos] == '$' || p.src[p.pos] == '`':
+					word.WriteString(p.substitution())
+				default:
+					word.WriteByte(p.src[p.pos])
+					p.pos++
+				}
+			}
+			p.pos++
+			inWord = true
+		case c == '$' || c == '`':
+			word.WriteString(p.substitution())
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+			p.pos++
+		}
+	}
+	endPipeline()
+}
+
+// operator consumes a control operator starting at p.pos and returns it.
+func (p *shellParser) operator() string {
+	for _, op := range []string{"&&", "||", ";;", "|&", "&>>", "&>", ";", "&", "|"} {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	p.pos++
+	return p.src[p.pos-1 : p.pos]
+}
+
+// redirect consumes a redirection operator and its target. Targets of output redirections are
+// recorded as written files; here-document delimiters are queued so their bodies are skipped.
+func (p *shellParser) redirect(cmd *shellCommand, output bool) {
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte("<>&|-", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+	op := p.src[start:p.pos]
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+	target := p.word()
+	switch {
+	case strings.HasPrefix(op, "<<<"):
+	case strings.HasPrefix(op, "<<"):
+		p.heredocs = append(p.heredocs, strings.Trim(target, `'"`))
+	case output && strings.HasSuffix(op, ">&") && strings.Trim(target, "0123456789-") == "":
+		// fd duplication such as 2>&1
+	case output || strings.Contains(op, ">"):
+		cmd.writes = append(cmd.writes, strings.Trim(target, `'"`))
+	}
+}
+
+// word consumes one unparsed word (up to whitespace or an operator) and returns it.
+func (p *shellParser) word() string {
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n;&|<>()", p.src[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// substitution consumes $(...), `...`, $((...)), ${...} or a plain $ at p.pos. Command
+// substitutions are parsed as pipelines of their own; the returned text stands in for the value.
+func (p *shellParser) substitution() string {
+	rest := p.src[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, "$(("):
+		p.pos++
+		return "$((" + p.enclosed('(', ')') + ")"
+	case strings.HasPrefix(rest, "$("):
+		p.pos++
+		p.pipelines = append(p.pipelines, parseShell(p.enclosed('(', ')'))...)
+		return "$(...)"
+	case strings.HasPrefix(rest, "${"):
+		p.pos++
+		return "${" + p.enclosed('{', '}') + "}"
+	case strings.HasPrefix(rest, "`"):
+		end := strings.IndexByte(rest[1:], '`')
+		if end < 0 {
+			end = len(rest) - 1
+		}
+		p.pipelines = append(p.pipelines, parseShell(rest[1:1+end])...)
+		p.pos += end + 2
+		return "$(...)"
+	}
+	p.pos++
+	return "$"
+}
+
+// enclosed consumes a bracketed region starting at p.pos (which holds open) and returns its
+// contents, honouring nesting and quotes.
+func (p *shellParser) enclosed(open, close byte) string {
+	start := p.pos + 1
+	depth := 0
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == '\\':
+			p.pos++
+		case c == '\'':
+			if end := strings.IndexByte(p.src[p.pos+1:], '\''); end >= 0 {
+				p.pos += end + 1
+			}
+		case c == '"':
+			for p.pos++; p.pos < len(p.src) && p.src[p.pos] != '"'; p.pos++ {
+				if p.src[p.pos] == '\\' {
+					p.pos++
+				}
+			}
+		case c == open:
+			depth++
+		case c == close:
+			depth--
+			if depth == 0 {
+				p.pos++
+				return p.src[start : p.pos-1]
+			}
+		}
+		p.pos++
+	}
+	return p.src[min(start, len(p.src)):]
+}
+
+// skipHeredocs skips the bodies of pending here-documents, which start after a newline.
+func (p *shellParser) skipHeredocs() {
+	for _, delim := range p.heredocs {
+		for p.pos < len(p.src) {
+			end := strings.IndexByte(p.src[p.pos:], '\n')
+			if end < 0 {
+				end = len(p.src) - p.pos
+			}
+			line := p.src[p.pos : p.pos+end]
+			p.pos = min(p.pos+end+1, len(p.src))
+			if strings.TrimLeft(line, "\t") == delim {
+				break
+			}
+		}
+	}
+	p.heredocs = nil
+}
+
+// ClassifyCommand classifies a shell command string. dir is the directory it runs in ("" for the
+// current directory); paths are judged against the agent's working directory as the workspace.
+func ClassifyCommand(command, dir string) CommandRisk {
+	c := classifier{dir: dir}
+	c.workspace, _ = os.Getwd()
+	if abs, err := filepath.Abs(dir); err == nil {
+		c.dir = abs
+	}
+	c.command(command)
+	return c.risk
+}
+
+// classifier accumulates the risk of the commands it is shown.
+type classifier struct {
+	workspace string
+	dir       string
+	risk      CommandRisk
+	depth     int // nesting of sh -c, eval and the like
+}
+
+func (c *classifier) add(display string, level RiskLevel, why string) {
+	c.risk.Level = max(c.risk.Level, level)
+	if level == RiskReadOnly {
+		return
+	}
+	if r := []rune(display); len(r) > 60 {
+		display = string(r[:57]) + "..."
+	}
+	reason := display + ": " + level.String()
+	if why != "" {
+		reason += " (" + why + ")"
+	}
+	for _, r := range c.risk.Reasons {
+		if r == reason {
+			return
+		}
+	}
+	c.risk.Reasons = append(c.risk.Reasons, reason)
+}
+
+// command classifies every pipeline in a command string.
+func (c *classifier) command(src string) {
+	if c.depth > 5 {
+		c.add(src, RiskWorkspace, "nested too deeply to analyse")
+		return
+	}
+	c.depth++
+	defer func() { c.depth-- }()
+	for _, pipeline := range parseShell(src) {
+		fetches := false
+		for _, cmd := range pipeline {
+			for _, target := range cmd.writes {
+				c.write(cmd.display, target)
+			}
+			words := c.unwrap(cmd)
+			if len(words) == 0 {
+				continue
+			}
+			name := filepath.Base(words[0])
+			if fetches && interpreters[name] && (!hasOperand(words[1:]) || containsWord(words[1:], "-")) {
+				c.add(cmd.display, RiskPrivileged, "runs downloaded code")
+			}
+			if interpreters[name] || name == "source" || name == "." {
+				for _, inner := range cmd.substituted {
+					if downloads(inner) {
+						c.add(cmd.display, RiskPrivileged, "runs downloaded code")
+					}
+				}
+			}
+			if fetchCommands[name] {
+				fetches = true
+			}
+			c.simple(cmd.display, name, words)
+		}
+	}
+}
+
+// downloads reports whether a command string runs curl or wget, whose output is then the
+// downloaded content.
+func downloads(src string) bool {
+	var scratch classifier // unwrap only records reasons, which are not wanted here
+	for _, pipeline := range parseShell(src) {
+		for _, cmd := range pipeline {
+			if words := scratch.unwrap(cmd); len(words) > 0 && fetchCommands[filepath.Base(words[0])] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unwrap strips shell keywords, variable assignments and wrappers such as sudo, env, nohup and
+// timeout, returning the words of the command that actually runs. sudo itself is recorded.
+func (c *classifier) unwrap(cmd shellCommand) []string {
+	words := cmd.words
+	for len(words) > 0 {
+		w := words[0]
+		switch {
+		case shellKeywords[w]:
+			if w == "for" || w == "case" || w == "select" {
+				return nil // loop and case headers run nothing
+			}
+			words = words[1:]
+		case strings.Contains(w, "=") && !strings.HasPrefix(w, "=") && !strings.ContainsAny(strings.SplitN(w, "=", 2)[0], "/$-"):
+			words = words[1:]
+		case w == "sudo" || w == "doas" || w == "pkexec" || w == "su":
+			c.add(cmd.display, RiskPrivileged, w+" runs as another user")
+			words = skipFlags(words[1:], "-u", "-g", "-c")
+			if w == "su" {
+				return nil
+			}
+		case commandWrappers[w]:
+			words = skipFlags(words[1:], "-n", "-s", "-k", "-o", "-e", "-i", "-L", "-c")
+			if w == "timeout" && len(words) > 0 {
+				words = words[1:] // the duration
+			}
+		default:
+			return words
+		}
+	}
+	return nil
+}
+
+// simple classifies one command by its program name and arguments.
+func (c *classifier) simple(display, name string, words []string) {
+	args := words[1:]
+	switch {
+	case name == "eval":
+		c.command(strings.Join(args, " "))
+	case interpreters[name] && inlineCommand(args) != "":
+		if shells[name] {
+			c.command(inlineCommand(args))
+		} else {
+			c.add(display, RiskWorkspace, "runs inline code")
+		}
+	case name == "git":
+		c.git(display, args)
+	case name == "go":
+		c.goTool(display, args)
+	case packageManagers[name] != 0:
+		c.packageManager(display, name, args)
+	case name == "find":
+		c.find(display, args)
+	case name == "xargs":
+		if rest := skipFlags(args, "-n", "-I", "-P", "-L", "-d", "-s", "-E"); len(rest) > 0 {
+			c.simple(display, filepath.Base(rest[0]), rest)
+		}
+	case name == "sed" || name == "perl":
+		if hasFlag(args, "-i") || hasFlag(args, "--in-place") {
+			c.paths(display, dropFirst(operands(args)), RiskWorkspace, "edits files in place")
+		} else if name == "perl" {
+			c.add(display, RiskWorkspace, "runs a script")
+		}
+	case name == "gofmt" || name == "goimports":
+		if hasFlag(args, "-w") {
+			c.paths(display, operands(args), RiskWorkspace, "rewrites files")
+		}
+	case name == "source" || name == ".":
+		c.add(display, RiskWorkspace, "runs a script in the shell")
+	case name == "rm" || name == "shred" || name == "unlink":
+		why := "deletes files"
+		if hasFlag(args, "-r") || hasFlag(args, "-R") || hasFlag(args, "--recursive") {
+			why = "deletes files recursively"
+		}
+		c.add(display, RiskDestructive, why)
+	case name == "truncate" || name == "dd":
+		c.add(display, RiskDestructive, "overwrites data")
+	case name == "kill" || name == "pkill" || name == "killall":
+		c.add(display, RiskDestructive, "stops processes")
+	case name == "rsync":
+		level, why := RiskWorkspace, "copies files"
+		if hasFlag(args, "--delete") {
+			level, why = RiskDestructive, "deletes files not in the source"
+		}
+		c.add(display, level, why)
+		for _, a := range operands(args) {
+			if strings.Contains(a, ":") && !strings.HasPrefix(a, "/") {
+				c.add(display, RiskNetwork, "copies to or from another host")
+				break
+			}
+		}
+	case name == "cp" || name == "mv" || name == "ln" || name == "install":
+		ops := operands(args)
+		if len(ops) > 0 {
+			c.write(display, ops[len(ops)-1])
+			if name == "mv" {
+				c.paths(display, ops[:len(ops)-1], RiskWorkspace, "moves files")
+			}
+		}
+		c.add(display, RiskWorkspace, "writes files")
+	case name == "tee":
+		for _, target := range operands(args) {
+			c.write(display, target)
+		}
+	case name == "chmod" || name == "chown" || name == "chgrp":
+		level := RiskWorkspace
+		if name != "chmod" {
+			level = RiskPrivileged
+		}
+		c.paths(display, dropFirst(operands(args)), level, "changes permissions")
+	case privilegedCommands[name] || privilegedCommands[strings.SplitN(name, ".", 2)[0]]:
+		// Dotted names are variants of the same tool: mkfs.ext4, mount.nfs, fsck.vfat.
+		c.add(display, RiskPrivileged, "changes the system")
+	case networkCommands[name]:
+		c.add(display, RiskNetwork, "network access")
+	case fileCommands[name]:
+		c.paths(display, operands(args), RiskWorkspace, "writes files")
+	case readOnlyCommands[name]:
+		c.add(display, RiskReadOnly, "")
+	default:
+		c.add(display, RiskWorkspace, "runs "+name)
+	}
+}
+
+// git classifies a git invocation by its subcommand and flags.
+func (c *classifier) git(display string, args []string) {
+	args = skipFlags(args, "-C", "-c", "--git-dir", "--work-tree")
+	if len(args) == 0 {
+		return
+	}
+	sub, rest := args[0], args[1:]
+	switch {
+	case sub == "push":
+		if hasFlag(rest, "--force") || hasFlag(rest, "-f") || hasFlag(rest, "--force-with-lease") || hasFlag(rest, "--delete") || hasFlag(rest, "-d") || forcedRefspec(rest) {
+			c.add(display, RiskDestructive, "rewrites or deletes remote history")
+		}
+		c.add(display, RiskNetwork, "publishes commits")
+	case sub == "fetch" || sub == "pull" || sub == "clone" || sub == "ls-remote" || sub == "submodule":
+		c.add(display, RiskNetwork, "network access")
+	case sub == "reset" && hasFlag(rest, "--hard"),
+		sub == "clean" && (hasFlag(rest, "-f") || hasFlag(rest, "--force")),
+		sub == "checkout" && hasOperand(rest) && containsWord(rest, "--", "."),
+		sub == "restore" && !hasFlag(rest, "--staged"),
+		sub == "branch" && (hasFlag(rest, "-D") || hasFlag(rest, "-d") || hasFlag(rest, "--delete")),
+		sub == "stash" && len(rest) > 0 && (rest[0] == "drop" || rest[0] == "clear"),
+		sub == "rebase", sub == "filter-branch", sub == "reflog" && len(rest) > 0 && rest[0] == "expire",
+		sub == "gc" && hasFlag(rest, "--prune=now"):
+		c.add(display, RiskDestructive, "discards commits or changes")
+	case gitReadOnly[sub], sub == "branch" && !hasOperand(rest), sub == "remote" && !hasOperand(rest),
+		sub == "stash" && len(rest) > 0 && (rest[0] == "list" || rest[0] == "show"),
+		sub == "config" && (hasFlag(rest, "--get") || hasFlag(rest, "--list") || hasFlag(rest, "-l")):
+		c.add(display, RiskReadOnly, "")
+	default:
+		c.add(display, RiskWorkspace, "changes the repository")
+	}
+}
+
+// goTool classifies a go command: builds and tests change the workspace, fetching modules uses
+// the network.
+func (c *classifier) goTool(display string, args []string) {
+	if len(args) == 0 {
+		return
+	}
+	switch args[0] {
+	case "version", "env", "list", "doc", "help", "vet":
+		if args[0] == "env" && hasFlag(args[1:], "-w") {
+			c.add(display, RiskWorkspace, "changes go settings")
+			return
+		}
+		c.add(display, RiskReadOnly, "")
+	case "get", "install":
+		c.add(display, RiskNetwork, "downloads modules")
+	case "mod":
+		if len(args) > 1 && (args[1] == "download" || args[1] == "tidy") {
+			c.add(display, RiskNetwork, "downloads modules")
+			return
+		}
+		c.add(display, RiskWorkspace, "changes go.mod")
+	case "clean":
+		if hasFlag(args[1:], "-modcache") || hasFlag(args[1:], "-cache") {
+			c.add(display, RiskDestructive, "deletes the Go caches")
+			return
+		}
+		c.add(display, RiskWorkspace, "removes build outputs")
+	default:
+		c.add(display, RiskWorkspace, "go "+args[0])
+	}
+}
+
+// packageManager classifies package manager commands: installs fetch from the network, and system
+// package managers need root.
+func (c *classifier) packageManager(display, name string, args []string) {
+	args = skipFlags(args)
+	if len(args) == 0 {
+		c.add(display, RiskWorkspace, "runs "+name)
+		return
+	}
+	switch sub := args[0]; {
+	case packageManagers[name] == systemPackages && installVerbs[sub]:
+		c.add(display, RiskPrivileged, "changes system packages")
+	case installVerbs[sub]:
+		c.add(display, RiskNetwork, "installs packages")
+	case sub == "publish" || sub == "push" || sub == "upload":
+		c.add(display, RiskNetwork, "publishes a package")
+	case sub == "list" || sub == "show" || sub == "info" || sub == "search" || sub == "view" || sub == "ls" || sub == "outdated":
+		c.add(display, RiskReadOnly, "")
+	default:
+		c.add(display, RiskWorkspace, name+" "+sub)
+	}
+}
+
+// find is read-only unless it deletes or executes commands, which are classified in turn.
+func (c *classifier) find(display string, args []string) {
+	c.add(display, RiskReadOnly, "")
+	for i, a := range args {
+		switch a {
+		case "-delete":
+			c.add(display, RiskDestructive, "deletes matching files")
+		case "-exec", "-execdir", "-ok", "-okdir":
+			var inner []string
+			for _, w := range args[i+1:] {
+				if w == ";" || w == "+" || w == `\;` {
+					break
+				}
+				inner = append(inner, w)
+			}
+			if len(inner) > 0 {
+				c.simple(display, filepath.Base(inner[0]), inner)
+			}
+		case "-fprint", "-fprintf", "-fls":
+			if i+1 < len(args) {
+				c.write(display, args[i+1])
+			}
+		}
+	}
+}
+
+// write records a file written by the command: inside the workspace or /tmp it changes the
+// workspace; elsewhere it is destructive. Device files such as /dev/null are ignored.
+func (c *classifier) write(display, target string) {
+	if display == "" {
+		display = "> " + target
+	}
+	switch {
+	case target == "" || strings.HasPrefix(target, "/dev/"):
+	case c.outside(target):
+		c.add(display, RiskDestructive, "writes outside the workspace: "+target)
+	default:
+		c.add(display, RiskWorkspace, "writes "+target)
+	}
+}
+
+// paths records that a command changes the given paths at level, escalating to destructive when
+// any of them is outside the workspace.
+func (c *classifier) paths(display string, paths []string, level RiskLevel, why string) {
+	for _, p := range paths {
+		if c.outside(p) {
+			c.add(display, max(level, RiskDestructive), why+" outside the workspace: "+p)
+			return
+		}
+	}
+	c.add(display, level, why)
+}
+
+// outside reports whether path is outside the workspace and the temp directory. Paths that depend
+// on variables other than the workspace-relative ones cannot be judged and count as outside only
+// when they start from the home directory.
+func (c *classifier) outside(path string) bool {
+	if strings.HasPrefix(path, "~") || strings.HasPrefix(path, "$HOME") || strings.HasPrefix(path, "${HOME}") {
+		return true
+	}
+	if strings.Contains(path, "$") || c.workspace == "" {
+		return false
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.dir, path)
+	}
+	path = filepath.Clean(path)
+	for _, root := range []string{c.workspace, os.TempDir(), "/tmp"} {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return false
+		}
+	}
+	return true
+}
+
+// skipFlags drops leading flags from args; flags listed in withValue also drop their value.
+func skipFlags(args []string, withValue ...string) []string {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-" {
+		flag := args[0]
+		args = args[1:]
+		if flag == "--" {
+			break
+		}
+		for _, v := range withValue {
+			if flag == v && len(args) > 0 {
+				args = args[1:]
+			}
+		}
+	}
+	return args
+}
+
+// hasFlag reports whether args contain flag, also inside combined short flags (-rf has -r and -f).
+func hasFlag(args []string, flag string) bool {
+	for _, a := range args {
+		if a == flag || strings.HasPrefix(a, flag+"=") {
+			return true
+		}
+		if len(flag) == 2 && flag[0] == '-' && flag[1] != '-' && len(a) > 2 && a[0] == '-' && a[1] != '-' && strings.IndexByte(a[1:], flag[1]) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// inlineCommand returns the code passed with -c, as in sh -c CMD, bash -ec CMD or python3 -c CODE:
+// the argument after the first leading short-flag cluster that contains c. It returns "" when the
+// options end (at an operand or --) before any such cluster.
+func inlineCommand(args []string) string {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" || a == "-" || !strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "+") {
+			return ""
+		}
+		switch a {
+		case "-o", "+o", "-O", "+O", "-W", "-X":
+			i++ // the option's value
+			continue
+		}
+		if !strings.HasPrefix(a, "--") && strings.IndexByte(a[1:], 'c') >= 0 {
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// forcedRefspec reports whether git push arguments include a refspec that force-pushes (+ref) or
+// deletes a remote ref (:ref).
+func forcedRefspec(args []string) bool {
+	ops := operands(args)
+	if len(ops) < 2 {
+		return false // no refspec after the remote
+	}
+	for _, ref := range ops[1:] {
+		if strings.HasPrefix(ref, "+") || strings.HasPrefix(ref, ":") && len(ref) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// operands returns the arguments that are not flags.
+func operands(args []string) []string {
+	var ops []string
+	for _, a := range args {
+		if !strings.HasPrefix(a, "-") || a == "-" {
+			ops = append(ops, a)
+		}
+	}
+	return ops
+}
+
+// dropFirst returns s without its first element, such as the mode given to chmod.
+func dropFirst(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return s[1:]
+}
+
+// hasOperand reports whether args contain a non-flag argument.
+func hasOperand(args []string) bool {
+	return len(operands(args)) > 0
+}
+
+// containsWord reports whether args contain any of words.
+func containsWord(args []string, words ...string) bool {
+	for _, a := range args {
+		for _, w := range words {
+			if a == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+var (
+	shellKeywords    = wordSet("if", "then", "else", "elif", "fi", "do", "done", "while", "until", "for", "case", "esac", "select", "!", "{", "}", "time", "function")
+	commandWrappers  = wordSet("env", "nohup", "nice", "ionice", "timeout", "command", "exec", "builtin", "stdbuf", "watch", "unbuffer", "caffeinate")
+	fetchCommands    = wordSet("curl", "wget")
+	interpreters     = wordSet("sh", "bash", "zsh", "dash", "ksh", "fish", "python", "python3", "perl", "ruby", "node", "php")
+	shells           = wordSet("sh", "bash", "zsh", "dash", "ksh", "fish")
+	gitReadOnly      = wordSet("status", "log", "diff", "show", "blame", "rev-parse", "ls-files", "ls-tree", "grep", "describe", "shortlog", "cat-file", "rev-list", "reflog", "whatchanged", "name-rev", "merge-base", "check-ignore", "for-each-ref", "show-ref", "help", "version")
+	readOnlyCommands = wordSet(
+		"ls", "cat", "head", "tail", "less", "more", "grep", "egrep", "fgrep", "rg", "ag", "ack", "wc", "echo", "printf",
+		"pwd", "cd", "pushd", "popd", "which", "whereis", "type", "file", "stat", "du", "df", "tree", "sort", "uniq", "cut",
+		"tr", "awk", "gawk", "jq", "yq", "diff", "cmp", "comm", "date", "printenv", "uname", "whoami", "id", "hostname",
+		"ps", "top", "htop", "true", "false", "test", "[", "[[", "basename", "dirname", "realpath", "readlink", "md5sum",
+		"sha1sum", "sha256sum", "shasum", "xxd", "od", "hexdump", "strings", "column", "nl", "tac", "rev", "seq", "sleep",
+		"man", "export", "set", "unset", "alias", "read", "local", "return", "exit", "shift", "wait", "jobs", "history",
+		"lsof", "free", "uptime", "nproc", "locale", "tput", "cal", "bc", "expr",
+		"getconf", "ldd", "nm", "objdump", "zcat", "gzcat", "bzcat", "xzcat", "base64", "cksum", "fold", "fmt",
+		"paste", "join", "split", "csplit", "look", "yes", "clear", "ulimit", "umask", "trap", "declare", "typeset",
+	)
+	fileCommands    = wordSet("mkdir", "rmdir", "touch", "tar", "unzip", "zip", "gzip", "gunzip", "bzip2", "xz", "unxz", "patch", "mktemp", "mkfifo", "chattr")
+	networkCommands = wordSet(
+		"curl", "wget", "ssh", "scp", "sftp", "ftp", "telnet", "nc", "ncat", "netcat", "ping", "dig", "nslookup", "host",
+		"traceroute", "gh", "http", "https", "aws", "gcloud", "az", "kubectl", "helm", "terraform", "heroku", "flyctl",
+		"vercel", "netlify", "socat", "mosh", "git-lfs",
+	)
+	privilegedCommands = wordSet(
+		"mount", "umount", "systemctl", "service", "shutdown", "reboot", "halt", "poweroff", "useradd", "userdel",
+		"usermod", "groupadd", "groupdel", "passwd", "visudo", "iptables", "ip6tables", "nft", "ufw", "modprobe",
+		"insmod", "rmmod", "sysctl", "crontab", "mkfs", "fdisk", "parted", "swapon", "swapoff", "chroot", "docker",
+		"podman", "launchctl", "setcap", "nsenter", "unshare", "losetup", "cryptsetup", "wipefs", "fsck", "mkswap",
+	)
+	installVerbs = wordSet("install", "i", "add", "update", "upgrade", "get", "download", "fetch", "remove", "uninstall", "rm", "purge", "autoremove", "ci", "sync", "dist-upgrade", "full-upgrade", "reinstall", "tap")
+)
+
+// Kinds of package manager.
+const (
+	projectPackages = iota + 1 // install into the project or user environment over the network
+	systemPackages             // install system-wide, as root
+)
+
+var packageManagers = map[string]int{
+	"npm": projectPackages, "yarn": projectPackages, "pnpm": projectPackages, "bun": projectPackages,
+	"pip": projectPackages, "pip3": projectPackages, "pipx": projectPackages, "uv": projectPackages, "poetry": projectPackages,
+	"cargo": projectPackages, "gem": projectPackages, "bundle": projectPackages, "composer": projectPackages,
+	"brew": projectPackages, "conda": projectPackages, "mvn": projectPackages, "gradle": projectPackages,
+	"apt": systemPackages, "apt-get": systemPackages, "yum": systemPackages, "dnf": systemPackages,
+	"pacman": systemPackages, "zypper": systemPackages, "apk": systemPackages, "snap": systemPackages,
+	"port": systemPackages,
+}
@@ -47,6 +47,9 @@ func CopyFile(input json.RawMessage) (string, error) {
 	if info.IsDir() {
 		return "", fmt.Errorf("copyFile: source is a directory: %s", fromPath)
 	}
+	if err := snapshotForWrite(toPath); err != nil {
+		return "", err
+	}
 	mode := info.Mode().Perm()
 	toDir := filepath.Dir(toPath)
 	if toDir != "." {
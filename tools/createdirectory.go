@@ -41,6 +41,9 @@ func CreateDirectory(input json.RawMessage) (string, error) {
 		}
 		return fmt.Sprintf("Directory already exists: %s", path), nil
 	}
+	if err := snapshotForWrite(path); err != nil {
+		return "", err
+	}
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return "", fmt.Errorf("createDirectory: %w", err)
 	}
@@ -11,7 +11,7 @@ import (
 // CreateFileDefinition is the tool that creates a new file with the given content.
 var CreateFileDefinition = ToolDefinition{
 	Name:        "create_file",
-	Description: "Create a new file at the given path with the given content. Use this when the user wants to create a new file. Pass the relative path and the full file content. Creates parent directories if needed. If the file already exists, it is overwritten.",
+	Description: "Create a new file at the given path with the given content. Use this when the user wants to create a new file. Pass the relative path and the full file content. Creates parent directories if needed. If the file already exists, it is overwritten. Returns a unified diff of the change (against the previous content when overwriting).",
 	InputSchema: CreateFileInputSchema,
 	Function:    CreateFile,
 }
@@ -32,14 +32,30 @@ func CreateFile(input json.RawMessage) (string, error) {
 		return "", fmt.Errorf("create_file input: %w", err)
 	}
 	path := filepath.Clean(createFileInput.Path)
+	old, readErr := os.ReadFile(path)
+	existed := readErr == nil
+	content, goNote, err := prepareGoWrite(path, createFileInput.Content)
+	if err != nil {
+		return "", fmt.Errorf("create_file: %w", err)
+	}
+	if err := snapshotForWrite(path); err != nil {
+		return "", err
+	}
 	dir := filepath.Dir(path)
 	if dir != "." {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return "", fmt.Errorf("create_file: mkdir %s: %w", dir, err)
 		}
 	}
-	if err := os.WriteFile(path, []byte(createFileInput.Content), 0644); err != nil {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("Created file %s", path), nil
+	diff := reportDiff("create_file", path, string(old), content, existed, false)
+	if existed {
+		if diff == "" {
+			return fmt.Sprintf("Overwrote file %s (content unchanged)%s", path, noteLine(goNote)), nil
+		}
+		return fmt.Sprintf("Overwrote file %s%s\n\n%s", path, noteLine(goNote), diff), nil
+	}
+	return fmt.Sprintf("Created file %s%s\n\n%s", path, noteLine(goNote), diff), nil
 }
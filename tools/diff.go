@@ -0,0 +1,241 @@
+// Package tools provides the line diff used to report file changes as unified diffs.
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffChars caps the diff embedded in a tool result; the full diff still goes to the event stream.
+const maxDiffChars = 8_000
+
+// diffContextLines is the number of unchanged lines shown around each change.
+const diffContextLines = 3
+
+// maxDiffEdits bounds the Myers search; beyond it the changed region is reported as one replacement.
+const maxDiffEdits = 1_000
+
+// diffOp is one line of an edit script: kind is ' ' (keep), '-' (delete) or '+' (insert).
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// splitLinesKeepEnds splits s into lines that keep their trailing "\n", so a missing final newline is a change.
+func splitLinesKeepEnds(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns an edit script turning a into b. Common prefix and suffix are trimmed first;
+// the middle uses Myers' algorithm, falling back to delete-all/insert-all when it is too different.
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// myersDiff computes a shortest edit script with Myers' O(ND) algorithm.
+func myersDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	replaceAll := func() []diffOp {
+		ops := make([]diffOp, 0, n+m)
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+		return ops
+	}
+	if n == 0 || m == 0 {
+		return replaceAll()
+	}
+	maxD := n + m
+	if maxD > maxDiffEdits {
+		maxD = maxDiffEdits
+	}
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+	found := false
+	for d := 0; d <= maxD && !found; d++ {
+		// Keep only the diagonals reachable from step d, so the trace grows with D^2 rather than D*(N+M).
+		trace = append(trace, append([]int{}, v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return replaceAll()
+	}
+
+	// Walk the trace backwards to recover the script.
+	var rev []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev, off := trace[d], d+1
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev[off+k-1] < prev[off+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[off+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			rev = append(rev, diffOp{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			rev = append(rev, diffOp{'+', b[y]})
+		} else {
+			x--
+			rev = append(rev, diffOp{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		rev = append(rev, diffOp{' ', a[x]})
+	}
+	ops := make([]diffOp, len(rev))
+	for i := range rev {
+		ops[i] = rev[len(rev)-1-i]
+	}
+	return ops
+}
+
+// unifiedDiff renders the change from oldContent to newContent as a unified diff for path.
+// existed=false renders the old side as /dev/null; deleted renders the new side as /dev/null.
+// It returns "" when the contents are identical.
+func unifiedDiff(path, oldContent, newContent string, existed, deleted bool) string {
+	if existed && !deleted && oldContent == newContent {
+		return ""
+	}
+	ops := diffLines(splitLinesKeepEnds(oldContent), splitLinesKeepEnds(newContent))
+
+	var b strings.Builder
+	oldName, newName := "a/"+path, "b/"+path
+	if !existed {
+		oldName = "/dev/null"
+	}
+	if deleted {
+		newName = "/dev/null"
+	}
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+
+	oldLine, newLine := 1, 1
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+		// Extend the hunk while changes are separated by at most 2*context unchanged lines.
+		start := i - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContextLines {
+				break
+			}
+			end = run
+		}
+		stop := end + diffContextLines
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+		hunkOld, hunkNew := oldLine-(i-start), newLine-(i-start)
+		var oldCount, newCount int
+		var body strings.Builder
+		for _, op := range ops[start:stop] {
+			switch op.kind {
+			case ' ':
+				oldCount++
+				newCount++
+			case '-':
+				oldCount++
+			case '+':
+				newCount++
+			}
+			body.WriteByte(op.kind)
+			body.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				body.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		if oldCount == 0 {
+			hunkOld--
+		}
+		if newCount == 0 {
+			hunkNew--
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", hunkOld, oldCount, hunkNew, newCount)
+		b.WriteString(body.String())
+		for _, op := range ops[i:stop] {
+			if op.kind != '+' {
+				oldLine++
+			}
+			if op.kind != '-' {
+				newLine++
+			}
+		}
+		i = stop
+	}
+	return b.String()
+}
+
+// diffForResult caps a diff for inclusion in a tool result.
+func diffForResult(diff string) string {
+	if len(diff) <= maxDiffChars {
+		return diff
+	}
+	return diff[:maxDiffChars] + fmt.Sprintf("\n[Diff truncated to %d characters.]\n", maxDiffChars)
+}
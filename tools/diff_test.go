@@ -0,0 +1,50 @@
+package tools
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"identical", "a\n", "a\n", ""},
+		{"empty file gains lines", "", "a\nb\n", "--- a/f.txt\n+++ b/f.txt\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"file emptied", "a\nb\n", "", "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{
+			"no trailing newline on either side",
+			"a\nb", "a\nc",
+			"--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+		{
+			"trailing newline removed",
+			"a\nb\n", "a\nb",
+			"--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+		},
+		{"CRLF lines keep their carriage returns", "a\r\nb\r\n", "a\r\nc\r\n", "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,2 @@\n a\r\n-b\r\n+c\r\n"},
+		{
+			"hunk at end of file",
+			"1\n2\n3\n4\n5\n6\n7\n8\n", "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"--- a/f.txt\n+++ b/f.txt\n@@ -6,3 +6,4 @@\n 6\n 7\n 8\n+9\n",
+		},
+		{
+			"distant changes make two hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "x\n2\n3\n4\n5\n6\n7\n8\n9\ny\n",
+			"--- a/f.txt\n+++ b/f.txt\n@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+y\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := unifiedDiff("f.txt", tt.old, tt.new, true, false); got != tt.want {
+			t.Errorf("%s: got\n%q\nwant\n%q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUnifiedDiffNewAndDeletedFiles(t *testing.T) {
+	if got, want := unifiedDiff("f.txt", "", "a\n", false, false), "--- /dev/null\n+++ b/f.txt\n@@ -0,0 +1,1 @@\n+a\n"; got != want {
+		t.Errorf("new file: got %q, want %q", got, want)
+	}
+	if got, want := unifiedDiff("f.txt", "a\n", "", true, true), "--- a/f.txt\n+++ /dev/null\n@@ -1,1 +0,0 @@\n-a\n"; got != want {
+		t.Errorf("deleted file: got %q, want %q", got, want)
+	}
+}
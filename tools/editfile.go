@@ -11,46 +11,278 @@ import (
 // EditFileDefinition is the tool that edits an existing file by replacing strings.
 var EditFileDefinition = ToolDefinition{
 	Name:        "edit_file",
-	Description: "Edit an existing file by replacing one string with another. Use this when you need to change specific text within a file. Pass the file path (relative to the working directory), the exact string to find (old_string), and the string to replace it with (new_string). All occurrences of old_string in the file are replaced. Returns the number of replacements made or an error.",
+	Description: "Edit an existing file by replacing one string with another. Use this when you need to change specific text within a file. Pass the file path (relative to the working directory), the exact string to find (old_string), and the string to replace it with (new_string). old_string must match exactly once unless replace_all is set or expected_count gives the number of occurrences to replace; if it matches several times the edit fails and reports the line of every match, so add surrounding context or pass start_line/end_line to scope the search. If there is no exact match, a whitespace-tolerant line match (ignoring indentation and spacing differences) is tried and reported. Returns the number of replacements made and a unified diff of the change, or an error.",
 	InputSchema: EditFileInputSchema,
 	Function:    EditFile,
 }
 
 // EditFileInput is the JSON shape for the edit_file tool.
 type EditFileInput struct {
-	Path      string `json:"path" jsonschema_description:"The relative path of the file to edit."`
-	OldString string `json:"old_string" jsonschema_description:"The exact string to find and replace in the file."`
-	NewString string `json:"new_string" jsonschema_description:"The string to replace old_string with."`
+	Path          string `json:"path" jsonschema_description:"The relative path of the file to edit."`
+	OldString     string `json:"old_string" jsonschema_description:"The exact string to find and replace in the file."`
+	NewString     string `json:"new_string" jsonschema_description:"The string to replace old_string with."`
+	ReplaceAll    bool   `json:"replace_all" jsonschema_description:"If true, replace every occurrence of old_string; otherwise it must be unique."`
+	ExpectedCount int    `json:"expected_count" jsonschema_description:"Optional number of occurrences expected; all of them are replaced and the edit fails if the count differs."`
+	StartLine     int    `json:"start_line" jsonschema_description:"Optional first line (1-based) of the region to search; 0 or omit means the start of the file."`
+	EndLine       int    `json:"end_line" jsonschema_description:"Optional last line (1-based, inclusive) of the region to search; 0 or omit means the end of the file."`
 }
 
 // EditFileInputSchema is the Anthropic tool input schema for edit_file.
 var EditFileInputSchema = GenerateSchema[EditFileInput]()
 
-// EditFile implements the edit_file tool: reads the file, replaces all occurrences of old_string with new_string, writes back.
+// EditFile implements the edit_file tool: reads the file, replaces the matched occurrence(s) of old_string with new_string, writes back.
 func EditFile(input json.RawMessage) (string, error) {
 	var editFileInput EditFileInput
 	if err := json.Unmarshal(input, &editFileInput); err != nil {
 		return "", fmt.Errorf("edit_file input: %w", err)
 	}
 	path := editFileInput.Path
-	oldStr := editFileInput.OldString
-	newStr := editFileInput.NewString
 
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return "", err
 	}
 	s := string(content)
-	if oldStr == "" {
-		return "", fmt.Errorf("edit_file: old_string must not be empty")
+	newContent, outcome, err := applyEdit(s, editFileInput)
+	if err != nil {
+		return "", fmt.Errorf("edit_file: %s: %w", path, err)
+	}
+	newContent, goNote, err := prepareGoWrite(path, newContent)
+	if err != nil {
+		return "", fmt.Errorf("edit_file: %w", err)
 	}
-	newContent := strings.ReplaceAll(s, oldStr, newStr)
-	count := strings.Count(s, oldStr)
-	if count == 0 {
-		return "", fmt.Errorf("edit_file: old_string not found in file")
+	if err := Checkpoints.Snapshot(path); err != nil {
+		return "", err
 	}
 	if err := os.WriteFile(path, []byte(newContent), 0644); err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("Replaced %d occurrence(s) of the given string in %s", count, path), nil
+	diff := reportDiff("edit_file", path, s, newContent, true, false)
+	return fmt.Sprintf("Replaced %d occurrence(s) of the given string in %s%s%s\n\n%s", outcome.count, path, outcome.describe(), noteLine(goNote), diff), nil
+}
+
+// editOutcome describes how applyEdit matched old_string.
+type editOutcome struct {
+	count int
+	lines []int
+	fuzzy bool
+}
+
+// describe returns a suffix for the tool result naming the matched lines and any fallback used.
+func (o editOutcome) describe() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, " (line%s %s)", plural(len(o.lines)), joinInts(o.lines))
+	if o.fuzzy {
+		b.WriteString("; no exact match, matched ignoring whitespace differences")
+	}
+	return b.String()
+}
+
+// editSpan is one matched region [start, end) and the text that replaces it.
+type editSpan struct {
+	start, end  int
+	replacement string
+}
+
+// applyEdit replaces old_string with new_string in content according to the uniqueness,
+// expected_count, replace_all and line-range rules of edit_file. Errors explain how to fix the call.
+func applyEdit(content string, in EditFileInput) (string, editOutcome, error) {
+	if in.OldString == "" {
+		return "", editOutcome{}, fmt.Errorf("old_string must not be empty")
+	}
+	if in.OldString == in.NewString {
+		return "", editOutcome{}, fmt.Errorf("old_string and new_string are identical")
+	}
+	lineStarts := lineOffsets(content)
+	lo, hi, err := lineRangeBounds(content, lineStarts, in.StartLine, in.EndLine)
+	if err != nil {
+		return "", editOutcome{}, err
+	}
+
+	spans := exactMatches(content, lo, hi, in.OldString, in.NewString)
+	fuzzy := false
+	if len(spans) == 0 {
+		spans = fuzzyLineMatches(content, lineStarts, lo, hi, in.OldString, in.NewString)
+		fuzzy = len(spans) > 0
+	}
+	outcome := editOutcome{count: len(spans), fuzzy: fuzzy}
+	for _, sp := range spans {
+		outcome.lines = append(outcome.lines, lineAt(lineStarts, sp.start))
+	}
+
+	scope := ""
+	if in.StartLine > 0 || in.EndLine > 0 {
+		scope = fmt.Sprintf(" in lines %d-%d", lineAt(lineStarts, lo), lineAt(lineStarts, max(lo, hi-1)))
+	}
+	switch {
+	case len(spans) == 0:
+		return "", outcome, fmt.Errorf("old_string not found%s", scope)
+	case in.ExpectedCount > 0 && len(spans) != in.ExpectedCount:
+		return "", outcome, fmt.Errorf("expected %d occurrence(s) of old_string%s but found %d (line%s %s)", in.ExpectedCount, scope, len(spans), plural(len(spans)), joinInts(outcome.lines))
+	case len(spans) > 1 && !in.ReplaceAll && in.ExpectedCount == 0:
+		return "", outcome, fmt.Errorf("old_string matches %d times%s (lines %s); include more surrounding context to make it unique, pass start_line/end_line, or set replace_all or expected_count", len(spans), scope, joinInts(outcome.lines))
+	}
+
+	var b strings.Builder
+	prev := 0
+	for _, sp := range spans {
+		b.WriteString(content[prev:sp.start])
+		b.WriteString(sp.replacement)
+		prev = sp.end
+	}
+	b.WriteString(content[prev:])
+	return b.String(), outcome, nil
+}
+
+// exactMatches returns the non-overlapping occurrences of old within content[lo:hi].
+func exactMatches(content string, lo, hi int, old, replacement string) []editSpan {
+	var spans []editSpan
+	for i := lo; i < hi; {
+		j := strings.Index(content[i:hi], old)
+		if j < 0 {
+			break
+		}
+		spans = append(spans, editSpan{start: i + j, end: i + j + len(old), replacement: replacement})
+		i += j + len(old)
+	}
+	return spans
+}
+
+// fuzzyLineMatches finds runs of whole lines in content[lo:hi] equal to the lines of old after
+// collapsing whitespace. The replacement is re-indented from old's indentation to the file's.
+func fuzzyLineMatches(content string, lineStarts []int, lo, hi int, old, replacement string) []editSpan {
+	keepNewline := strings.HasSuffix(old, "\n")
+	oldLines := strings.Split(strings.TrimSuffix(old, "\n"), "\n")
+	want := make([]string, len(oldLines))
+	blank := true
+	for i, l := range oldLines {
+		want[i] = strings.Join(strings.Fields(l), " ")
+		if want[i] != "" {
+			blank = false
+		}
+	}
+	if blank {
+		return nil
+	}
+	lineText := func(i int) string {
+		end := len(content)
+		if i+1 < len(lineStarts) {
+			end = lineStarts[i+1] - 1
+		} else if strings.HasSuffix(content, "\n") {
+			end--
+		}
+		return content[lineStarts[i]:end]
+	}
+	first := lineAt(lineStarts, lo) - 1
+	var spans []editSpan
+	for i := first; i+len(want) <= len(lineStarts) && lineStarts[i] < hi; i++ {
+		last := i + len(want) - 1
+		if lineStarts[last] >= hi {
+			break
+		}
+		match := true
+		for k := range want {
+			if strings.Join(strings.Fields(lineText(i+k)), " ") != want[k] {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+		end := lineStarts[last] + len(lineText(last))
+		if keepNewline && end < len(content) {
+			end++
+		}
+		spans = append(spans, editSpan{
+			start:       lineStarts[i],
+			end:         end,
+			replacement: reindent(replacement, leadingSpace(oldLines[0]), leadingSpace(lineText(i))),
+		})
+		i = last
+	}
+	return spans
+}
+
+// reindent replaces the from indentation prefix with to on every line of s that starts with it.
+func reindent(s, from, to string) string {
+	if from == to {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if l != "" && strings.HasPrefix(l, from) {
+			lines[i] = to + l[len(from):]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// leadingSpace returns the leading spaces and tabs of s.
+func leadingSpace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
+
+// lineOffsets returns the byte offset at which each line of content starts.
+func lineOffsets(content string) []int {
+	offsets := []int{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' && i+1 < len(content) {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+// lineAt returns the 1-based line number containing byte offset off.
+func lineAt(lineStarts []int, off int) int {
+	lo, hi := 0, len(lineStarts)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if lineStarts[mid] <= off {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// lineRangeBounds converts an optional 1-based inclusive line range into byte offsets [lo, hi).
+func lineRangeBounds(content string, lineStarts []int, start, end int) (int, int, error) {
+	if start <= 0 && end <= 0 {
+		return 0, len(content), nil
+	}
+	if start <= 0 {
+		start = 1
+	}
+	if end <= 0 || end > len(lineStarts) {
+		end = len(lineStarts)
+	}
+	if start > len(lineStarts) {
+		return 0, 0, fmt.Errorf("start_line %d is beyond file length %d", start, len(lineStarts))
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("end_line must be >= start_line")
+	}
+	hi := len(content)
+	if end < len(lineStarts) {
+		hi = lineStarts[end]
+	}
+	return lineStarts[start-1], hi, nil
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+func joinInts(nums []int) string {
+	parts := make([]string, len(nums))
+	for i, n := range nums {
+		parts[i] = fmt.Sprint(n)
+	}
+	return strings.Join(parts, ", ")
 }
@@ -0,0 +1,55 @@
+// Package tools provides the structured event stream tools use to notify front-ends.
+package tools
+
+import "sync"
+
+// Event is a structured notification emitted by a tool while it runs, e.g. the diff of a file it changed.
+type Event struct {
+	Type string `json:"type"`
+	Tool string `json:"tool,omitempty"`
+	Path string `json:"path,omitempty"`
+	Diff string `json:"diff,omitempty"`
+
+	// Command events: the shell command about to run and its risk classification.
+	Command string   `json:"command,omitempty"`
+	Risk    string   `json:"risk,omitempty"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// EventFileDiff is the Event type carrying the unified diff of a file change.
+const EventFileDiff = "file_diff"
+
+// EventCommand is the Event type announcing a shell command with its risk, before it runs.
+const EventCommand = "command"
+
+var (
+	eventMu   sync.Mutex
+	eventSink func(Event)
+)
+
+// SetEventSink installs the function that receives tool events; nil discards them.
+func SetEventSink(fn func(Event)) {
+	eventMu.Lock()
+	defer eventMu.Unlock()
+	eventSink = fn
+}
+
+// emitEvent forwards e to the installed sink, if any.
+func emitEvent(e Event) {
+	eventMu.Lock()
+	sink := eventSink
+	eventMu.Unlock()
+	if sink != nil {
+		sink(e)
+	}
+}
+
+// reportDiff emits a file_diff event for a change and returns the capped diff for the tool result.
+func reportDiff(tool, path, oldContent, newContent string, existed, deleted bool) string {
+	diff := unifiedDiff(path, oldContent, newContent, existed, deleted)
+	if diff == "" {
+		return ""
+	}
+	emitEvent(Event{Type: EventFileDiff, Tool: tool, Path: path, Diff: diff})
+	return diffForResult(diff)
+}
@@ -75,6 +75,9 @@ func FetchFile(input json.RawMessage) (string, error) {
 	savePath := strings.TrimSpace(in.SavePath)
 	if savePath != "" {
 		savePath = filepath.Clean(savePath)
+		if err := snapshotForWrite(savePath); err != nil {
+			return "", err
+		}
 		dir := filepath.Dir(savePath)
 		if dir != "." {
 			if err := os.MkdirAll(dir, 0755); err != nil {
@@ -2,6 +2,7 @@
 package tools
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +10,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
 const fetchHTMLMaxBytes = 5 * 1024 * 1024   // 5 MB read limit
@@ -18,66 +21,109 @@ const fetchHTMLTimeout = 30 * time.Second
 // FetchHTMLDefinition is the tool that fetches the HTML or text body of a URL.
 var FetchHTMLDefinition = ToolDefinition{
 	Name:        "fetchHtml",
-	Description: "Fetch the HTML or text body of a URL. Use this when you need to read the content of a web page. Returns the response body as text; for non-2xx status the body is still returned with a status line so you can reason about the response.",
+	Description: "Fetch a web page. By default (mode \"readable\") HTML pages are reduced to their main content as Markdown: scripts, styles, navigation, sidebars and footers are dropped and headings, lists, links, tables and code blocks kept, under a title and URL header. Set mode to \"raw\" for the HTML itself. Non-HTML bodies are returned as text. For a non-2xx status the body is still returned with a status line so you can reason about the response.",
 	InputSchema: FetchHTMLInputSchema,
 	Function:    FetchHTML,
 }
 
 // FetchHTMLInput is the JSON shape for the fetchHtml tool.
 type FetchHTMLInput struct {
-	URL string `json:"url" jsonschema_description:"The full URL to fetch (must be http or https)."`
+	URL  string `json:"url" jsonschema_description:"The full URL to fetch (must be http or https)."`
+	Mode string `json:"mode" jsonschema_description:"readable (default): the page's main content as Markdown; raw: the response body as-is, e.g. HTML source."`
 }
 
 // FetchHTMLInputSchema is the Anthropic tool input schema for fetchHtml.
 var FetchHTMLInputSchema = GenerateSchema[FetchHTMLInput]()
 
-// FetchHTML implements the fetchHtml tool: GETs the URL and returns the body as string.
+// FetchHTML implements the fetchHtml tool: GETs the URL and returns the page as Markdown or the raw body.
 func FetchHTML(input json.RawMessage) (string, error) {
 	var in FetchHTMLInput
 	if err := json.Unmarshal(input, &in); err != nil {
 		return "", fmt.Errorf("fetchHtml input: %w", err)
 	}
-	rawURL := strings.TrimSpace(in.URL)
+	mode := strings.TrimSpace(in.Mode)
+	switch mode {
+	case "":
+		mode = "readable"
+	case "readable", "raw":
+	default:
+		return "", fmt.Errorf("fetchHtml: mode must be readable or raw, not %q", in.Mode)
+	}
+	resp, body, err := fetchURL("fetchHtml", in.URL)
+	if err != nil {
+		return "", err
+	}
+	bodyStr := string(body)
+	if mode == "readable" && isHTML(resp.Header.Get("Content-Type"), body) {
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+		if err != nil {
+			return "", fmt.Errorf("fetchHtml: parse HTML: %w", err)
+		}
+		page := htmlToMarkdown(doc, resp.Request.URL)
+		header := "URL: " + resp.Request.URL.String()
+		if page.title != "" {
+			header = "Title: " + page.title + "\n" + header
+		}
+		if page.markdown == "" {
+			page.markdown = "[No readable content found; try mode \"raw\".]"
+		}
+		bodyStr = header + "\n\n" + page.markdown
+	}
+	if len(bodyStr) > fetchHTMLMaxReturnChars {
+		bodyStr = bodyStr[:fetchHTMLMaxReturnChars] + "\n\n[Content truncated to " + fmt.Sprintf("%d", fetchHTMLMaxReturnChars) + " characters.]"
+	}
+
+	statusLine := fmt.Sprintf("HTTP status: %d %s", resp.StatusCode, resp.Status)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return bodyStr, nil
+	}
+	return statusLine + "\n\n" + bodyStr, nil
+}
+
+// isHTML reports whether a response is an HTML or XHTML page, going by its Content-Type or, when
+// that is missing, its first bytes.
+func isHTML(contentType string, body []byte) bool {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	contentType = strings.ToLower(contentType)
+	return strings.Contains(contentType, "text/html") || strings.Contains(contentType, "application/xhtml")
+}
+
+// fetchURL GETs an http or https URL and reads up to fetchHTMLMaxBytes of the body. Errors are
+// prefixed with tool.
+func fetchURL(tool, rawURL string) (*http.Response, []byte, error) {
+	rawURL = strings.TrimSpace(rawURL)
 	if rawURL == "" {
-		return "", fmt.Errorf("fetchHtml: url is required")
+		return nil, nil, fmt.Errorf("%s: url is required", tool)
 	}
 	parsed, err := url.Parse(rawURL)
 	if err != nil {
-		return "", fmt.Errorf("fetchHtml: invalid url: %w", err)
+		return nil, nil, fmt.Errorf("%s: invalid url: %w", tool, err)
 	}
 	if parsed.Scheme != "http" && parsed.Scheme != "https" {
-		return "", fmt.Errorf("fetchHtml: url must use http or https scheme")
+		return nil, nil, fmt.Errorf("%s: url must use http or https scheme", tool)
 	}
 	if parsed.Host == "" {
-		return "", fmt.Errorf("fetchHtml: url must have a host")
+		return nil, nil, fmt.Errorf("%s: url must have a host", tool)
 	}
 
 	client := &http.Client{Timeout: fetchHTMLTimeout}
 	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("fetchHtml: request: %w", err)
+		return nil, nil, fmt.Errorf("%s: request: %w", tool, err)
 	}
 	req.Header.Set("User-Agent", "agentExample/1.0")
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("fetchHtml: %w", err)
+		return nil, nil, fmt.Errorf("%s: %w", tool, err)
 	}
 	defer resp.Body.Close()
 
-	limited := io.LimitReader(resp.Body, fetchHTMLMaxBytes)
-	body, err := io.ReadAll(limited)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, fetchHTMLMaxBytes))
 	if err != nil {
-		return "", fmt.Errorf("fetchHtml: read body: %w", err)
+		return nil, nil, fmt.Errorf("%s: read body: %w", tool, err)
 	}
-	bodyStr := string(body)
-	if len(bodyStr) > fetchHTMLMaxReturnChars {
-		bodyStr = bodyStr[:fetchHTMLMaxReturnChars] + "\n\n[Content truncated to " + fmt.Sprintf("%d", fetchHTMLMaxReturnChars) + " characters.]"
-	}
-
-	statusLine := fmt.Sprintf("HTTP status: %d %s", resp.StatusCode, resp.Status)
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return bodyStr, nil
-	}
-	return statusLine + "\n\n" + bodyStr, nil
+	return resp, body, nil
 }
@@ -0,0 +1,255 @@
+// Package tools provides the findFiles tool for the agent.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// FindFilesDefinition is the tool that finds files by glob or fuzzy name.
+var FindFilesDefinition = ToolDefinition{
+	Name:        "findFiles",
+	Description: "Find files under a directory by doublestar glob over relative paths (e.g. **/*_test.go, src/**/view*.ts; a glob without / matches the file name at any depth) and/or by fuzzy query like an editor's quick-open (\"chatviewprov\" or \"chat view provider\" finds chatViewProvider.ts), ranked by match quality. Results can be sorted by score, path or modification time (newest first) and are capped by maxResults. Files ignored by .gitignore or .agentignore and directories like .git and node_modules are skipped unless includeIgnored is set.",
+	InputSchema: FindFilesInputSchema,
+	Function:    FindFiles,
+}
+
+// FindFilesInput is the JSON shape for the findFiles tool.
+type FindFilesInput struct {
+	RootPath       string `json:"rootPath" jsonschema_description:"Directory to search in; default is current directory (.)."`
+	Glob           string `json:"glob" jsonschema_description:"Optional doublestar glob matched against paths relative to rootPath (** crosses directories). A glob without / matches the file name at any depth."`
+	Query          string `json:"query" jsonschema_description:"Optional fuzzy query; its characters must appear in order in the relative path (case-insensitive, spaces ignored). Matches at word and path-segment starts rank higher."`
+	SortBy         string `json:"sortBy" jsonschema_description:"Optional: score (default with a query), path (default otherwise), or mtime (most recently modified first)."`
+	IncludeDirs    bool   `json:"includeDirs" jsonschema_description:"If true, directories can match too (shown with a trailing /)."`
+	MaxResults     int    `json:"maxResults" jsonschema_description:"Optional cap on results; 0 or omit means 50."`
+	IncludeIgnored bool   `json:"includeIgnored" jsonschema_description:"If true, also search files ignored by .gitignore/.agentignore and junk directories such as node_modules (.git is always skipped)."`
+}
+
+// FindFilesInputSchema is the Anthropic tool input schema for findFiles.
+var FindFilesInputSchema = GenerateSchema[FindFilesInput]()
+
+const defaultFindFilesMax = 50
+
+// foundFile is one findFiles candidate.
+type foundFile struct {
+	path  string
+	score int
+	mtime time.Time
+}
+
+// FindFiles implements the findFiles tool: walks the tree, keeps paths matching the glob and query, sorts and caps them.
+func FindFiles(input json.RawMessage) (string, error) {
+	var findFilesInput FindFilesInput
+	if err := json.Unmarshal(input, &findFilesInput); err != nil {
+		return "", fmt.Errorf("findFiles input: %w", err)
+	}
+	rootPath := findFilesInput.RootPath
+	if rootPath == "" {
+		rootPath = "."
+	}
+	rootPath = filepath.Clean(rootPath)
+	glob := strings.TrimSpace(findFilesInput.Glob)
+	query := strings.Join(strings.Fields(findFilesInput.Query), "")
+	if glob == "" && query == "" {
+		return "", fmt.Errorf("findFiles: glob or query is required")
+	}
+	sortBy := findFilesInput.SortBy
+	if sortBy == "" {
+		sortBy = "path"
+		if query != "" {
+			sortBy = "score"
+		}
+	}
+	if sortBy != "score" && sortBy != "path" && sortBy != "mtime" {
+		return "", fmt.Errorf("findFiles: sortBy must be score, path or mtime")
+	}
+	maxResults := findFilesInput.MaxResults
+	if maxResults <= 0 {
+		maxResults = defaultFindFilesMax
+	}
+	var globRe *regexp.Regexp
+	if glob != "" {
+		expr := "^" + globToRegexp(strings.TrimPrefix(glob, "./")) + "$"
+		if !strings.Contains(glob, "/") {
+			expr = "^(?:.*/)?" + globToRegexp(glob) + "$"
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return "", fmt.Errorf("findFiles: invalid glob %q: %w", glob, err)
+		}
+		globRe = re
+	}
+	info, err := os.Stat(rootPath)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("findFiles: rootPath must be a directory: %s", rootPath)
+	}
+
+	var found []foundFile
+	err = walkTree(rootPath, findFilesInput.IncludeIgnored, func(path string, d os.DirEntry) error {
+		if d.IsDir() && !findFilesInput.IncludeDirs {
+			return nil
+		}
+		rel, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if globRe != nil && !globRe.MatchString(rel) {
+			return nil
+		}
+		f := foundFile{path: path}
+		if query != "" {
+			score, ok := fuzzyScore(query, rel)
+			if !ok {
+				return nil
+			}
+			f.score = score
+		}
+		if sortBy == "mtime" {
+			if info, err := d.Info(); err == nil {
+				f.mtime = info.ModTime()
+			}
+		}
+		if d.IsDir() {
+			f.path += "/"
+		}
+		found = append(found, f)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(found) == 0 {
+		return fmt.Sprintf("No files matching %s under %s", describeFind(glob, query), rootPath), nil
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		switch sortBy {
+		case "score":
+			if a.score != b.score {
+				return a.score > b.score
+			}
+			if len(a.path) != len(b.path) {
+				return len(a.path) < len(b.path)
+			}
+		case "mtime":
+			if !a.mtime.Equal(b.mtime) {
+				return a.mtime.After(b.mtime)
+			}
+		}
+		return a.path < b.path
+	})
+	lines := make([]string, 0, maxResults+1)
+	for i, f := range found {
+		if i == maxResults {
+			lines = append(lines, fmt.Sprintf("[%d more matches not shown; raise maxResults or narrow the search.]", len(found)-maxResults))
+			break
+		}
+		if sortBy == "mtime" {
+			lines = append(lines, fmt.Sprintf("%s  (%s)", f.path, f.mtime.Format("2006-01-02 15:04")))
+		} else {
+			lines = append(lines, f.path)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// describeFind renders the search criteria for the no-match message.
+func describeFind(glob, query string) string {
+	switch {
+	case glob != "" && query != "":
+		return fmt.Sprintf("glob %q and query %q", glob, query)
+	case glob != "":
+		return fmt.Sprintf("glob %q", glob)
+	default:
+		return fmt.Sprintf("query %q", query)
+	}
+}
+
+// Fuzzy match scoring: every matched character earns fuzzyMatchBonus, plus extra when it starts a
+// path segment or word, continues the previous match, or falls in the file name. Gaps cost a little.
+const (
+	fuzzyMatchBonus       = 1
+	fuzzySegmentBonus     = 8
+	fuzzyCamelBonus       = 6
+	fuzzyConsecutiveBonus = 5
+	fuzzyBaseNameBonus    = 2
+	fuzzyGapPenalty       = 2
+)
+
+// fuzzyScore reports whether query's characters appear in order in target (case-insensitively) and,
+// if so, the score of the best such alignment. Higher is better.
+func fuzzyScore(query, target string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	orig := []rune(target)
+	t := []rune(strings.ToLower(target))
+	if len(q) == 0 || len(q) > len(t) {
+		return 0, len(q) == 0
+	}
+	baseStart := strings.LastIndex(target, "/") + 1
+	baseStart = len([]rune(target[:baseStart]))
+	bonus := make([]int, len(t))
+	for j := range t {
+		bonus[j] = fuzzyMatchBonus
+		switch {
+		case j == 0 || strings.ContainsRune("/_-. ", orig[j-1]):
+			bonus[j] += fuzzySegmentBonus
+		case unicode.IsUpper(orig[j]) && unicode.IsLower(orig[j-1]):
+			bonus[j] += fuzzyCamelBonus
+		}
+		if j >= baseStart {
+			bonus[j] += fuzzyBaseNameBonus
+		}
+	}
+
+	// prev[j] is the best score with the previous query rune matched at t[j]; noMatch marks impossible.
+	const noMatch = -1 << 30
+	prev := make([]int, len(t))
+	cur := make([]int, len(t))
+	for j := range t {
+		prev[j] = noMatch
+		if t[j] == q[0] {
+			prev[j] = bonus[j]
+		}
+	}
+	for i := 1; i < len(q); i++ {
+		best := noMatch // best prev[k] for k < j-1
+		for j := range t {
+			cur[j] = noMatch
+			if j >= 2 && prev[j-2] > best {
+				best = prev[j-2]
+			}
+			if t[j] != q[i] {
+				continue
+			}
+			if j >= 1 && prev[j-1] != noMatch {
+				cur[j] = prev[j-1] + bonus[j] + fuzzyConsecutiveBonus
+			}
+			if best != noMatch && best+bonus[j]-fuzzyGapPenalty > cur[j] {
+				cur[j] = best + bonus[j] - fuzzyGapPenalty
+			}
+		}
+		prev, cur = cur, prev
+	}
+	result := noMatch
+	for _, s := range prev {
+		if s > result {
+			result = s
+		}
+	}
+	if result == noMatch {
+		return 0, false
+	}
+	return result, true
+}
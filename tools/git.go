@@ -0,0 +1,39 @@
+// Package tools provides shared helpers for the git_* tools.
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const defaultGitMaxChars = 20_000
+
+// runGit runs git with the given arguments in the working directory and returns stdout.
+// A non-zero exit is reported as an error carrying git's stderr.
+func runGit(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
+
+// capOutput truncates s to maxChars (defaultGitMaxChars when <= 0) and appends a note saying so.
+func capOutput(s string, maxChars int) string {
+	if maxChars <= 0 {
+		maxChars = defaultGitMaxChars
+	}
+	if len(s) <= maxChars {
+		return s
+	}
+	return s[:maxChars] + fmt.Sprintf("\n\n[Output truncated to %d characters; narrow the request (paths, line range, stat) to see more.]", maxChars)
+}
@@ -0,0 +1,146 @@
+// Package tools provides the git_blame tool for the agent.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitBlameDefinition is the tool that shows who last changed each line in a range of a file.
+var GitBlameDefinition = ToolDefinition{
+	Name:        "git_blame",
+	Description: "Show, for each line in a range of a file, the commit, author and date that last changed it, followed by the line. Always pass a line range for large files.",
+	InputSchema: GitBlameInputSchema,
+	Function:    GitBlame,
+}
+
+// GitBlameInput is the JSON shape for the git_blame tool.
+type GitBlameInput struct {
+	Path      string `json:"path" jsonschema_description:"The relative path of the file."`
+	StartLine int    `json:"startLine" jsonschema_description:"First line to blame (1-based); 0 or omit means 1."`
+	EndLine   int    `json:"endLine" jsonschema_description:"Last line to blame (inclusive); 0 or omit means startLine+99."`
+	Ref       string `json:"ref" jsonschema_description:"Optional commit to blame at; default is the working tree."`
+}
+
+// GitBlameInputSchema is the Anthropic tool input schema for git_blame.
+var GitBlameInputSchema = GenerateSchema[GitBlameInput]()
+
+const defaultGitBlameLines = 100
+
+type blameCommit struct {
+	author  string
+	date    string
+	summary string
+}
+
+// GitBlame implements the git_blame tool: parses git blame --porcelain into one compact line per source line.
+func GitBlame(input json.RawMessage) (string, error) {
+	var in GitBlameInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("git_blame input: %w", err)
+	}
+	if in.Path == "" {
+		return "", fmt.Errorf("git_blame: path is required")
+	}
+	start := in.StartLine
+	if start < 1 {
+		start = 1
+	}
+	end := in.EndLine
+	if end < 1 {
+		end = start + defaultGitBlameLines - 1
+	}
+	if end < start {
+		return "", fmt.Errorf("git_blame: endLine must be >= startLine")
+	}
+	args := []string{"blame", "--porcelain", "-L", fmt.Sprintf("%d,%d", start, end)}
+	if ref := strings.TrimSpace(in.Ref); ref != "" {
+		if strings.HasPrefix(ref, "-") {
+			return "", fmt.Errorf("git_blame: invalid ref %q", ref)
+		}
+		args = append(args, ref)
+	}
+	args = append(args, "--", in.Path)
+	out, err := runGit(args...)
+	if err != nil {
+		// git refuses ranges past EOF; retry once clamped to the file end.
+		if in.EndLine < 1 && strings.Contains(err.Error(), "has only") {
+			args[3] = fmt.Sprintf("%d,", start)
+			out, err = runGit(args...)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	commits := map[string]*blameCommit{}
+	var lines []string
+	var sha string
+	var lineNo int
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "\t") {
+			c := commits[sha]
+			short := sha
+			if len(short) > 8 {
+				short = short[:8]
+			}
+			if strings.Trim(sha, "0") == "" {
+				short = "uncommit"
+			}
+			lines = append(lines, fmt.Sprintf("%d %s (%s %s): %s", lineNo, short, c.author, c.date, line[1:]))
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) >= 3 && len(parts[0]) == 40 {
+			sha = parts[0]
+			lineNo, _ = strconv.Atoi(parts[2])
+			if commits[sha] == nil {
+				commits[sha] = &blameCommit{}
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		c := commits[sha]
+		if c == nil {
+			continue
+		}
+		switch key {
+		case "author":
+			c.author = value
+		case "author-time":
+			if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+				c.date = time.Unix(sec, 0).Format("2006-01-02")
+			}
+		case "summary":
+			c.summary = value
+		}
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("No lines to blame in %s", in.Path), nil
+	}
+
+	// List each commit's subject once so the per-line output stays short.
+	var summaries []string
+	seen := map[string]bool{}
+	for _, l := range lines {
+		short := strings.Fields(l)[1]
+		if seen[short] || short == "uncommit" {
+			continue
+		}
+		seen[short] = true
+		for full, c := range commits {
+			if strings.HasPrefix(full, short) {
+				summaries = append(summaries, fmt.Sprintf("%s %s", short, c.summary))
+				break
+			}
+		}
+	}
+	result := strings.Join(lines, "\n")
+	if len(summaries) > 0 {
+		result += "\n\ncommits:\n" + strings.Join(summaries, "\n")
+	}
+	return capOutput(result, 0), nil
+}
@@ -0,0 +1,123 @@
+// Package tools provides the git_branch and git_commit tools for the agent.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// GitBranchDefinition is the tool that creates (and optionally switches to) a branch.
+var GitBranchDefinition = ToolDefinition{
+	Name:        "git_branch",
+	Description: "Create a new git branch, optionally from a start point, and optionally switch to it. Requires user approval.",
+	InputSchema: GitBranchInputSchema,
+	Function:    GitBranch,
+}
+
+// GitBranchInput is the JSON shape for the git_branch tool.
+type GitBranchInput struct {
+	Name       string `json:"name" jsonschema_description:"The new branch name."`
+	StartPoint string `json:"startPoint" jsonschema_description:"Optional commit or branch to start from; default HEAD."`
+	Checkout   bool   `json:"checkout" jsonschema_description:"If true, switch to the new branch after creating it."`
+}
+
+// GitBranchInputSchema is the Anthropic tool input schema for git_branch.
+var GitBranchInputSchema = GenerateSchema[GitBranchInput]()
+
+// GitBranch implements the git_branch tool: git branch <name> [start], or git switch -c when checkout is set.
+func GitBranch(input json.RawMessage) (string, error) {
+	var in GitBranchInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("git_branch input: %w", err)
+	}
+	name := strings.TrimSpace(in.Name)
+	if name == "" || strings.HasPrefix(name, "-") {
+		return "", fmt.Errorf("git_branch: invalid branch name %q", in.Name)
+	}
+	if _, err := runGit("check-ref-format", "--branch", name); err != nil {
+		return "", fmt.Errorf("git_branch: invalid branch name %q", name)
+	}
+	start := strings.TrimSpace(in.StartPoint)
+	if strings.HasPrefix(start, "-") {
+		return "", fmt.Errorf("git_branch: invalid start point %q", start)
+	}
+	args := []string{"branch", name}
+	summary := "create branch " + name
+	if in.Checkout {
+		args = []string{"switch", "-c", name}
+		summary = "create and switch to branch " + name
+	}
+	if start != "" {
+		args = append(args, start)
+		summary += " from " + start
+	}
+	if err := requireApproval(ApprovalRequest{Tool: "git_branch", Summary: summary}); err != nil {
+		return "", err
+	}
+	if _, err := runGit(args...); err != nil {
+		return "", err
+	}
+	if in.Checkout {
+		return fmt.Sprintf("Created and switched to branch %s", name), nil
+	}
+	return fmt.Sprintf("Created branch %s", name), nil
+}
+
+// GitCommitDefinition is the tool that stages the given paths and creates a commit.
+var GitCommitDefinition = ToolDefinition{
+	Name:        "git_commit",
+	Description: "Create a git commit with the given message. Optionally stage specific paths first, or set all to stage every modified tracked file. Requires user approval. Returns the new commit's summary.",
+	InputSchema: GitCommitInputSchema,
+	Function:    GitCommit,
+}
+
+// GitCommitInput is the JSON shape for the git_commit tool.
+type GitCommitInput struct {
+	Message string   `json:"message" jsonschema_description:"The commit message."`
+	Paths   []string `json:"paths" jsonschema_description:"Optional paths to stage (git add) before committing."`
+	All     bool     `json:"all" jsonschema_description:"If true, stage all modified and deleted tracked files (git commit -a)."`
+}
+
+// GitCommitInputSchema is the Anthropic tool input schema for git_commit.
+var GitCommitInputSchema = GenerateSchema[GitCommitInput]()
+
+// GitCommit implements the git_commit tool.
+func GitCommit(input json.RawMessage) (string, error) {
+	var in GitCommitInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("git_commit input: %w", err)
+	}
+	message := strings.TrimSpace(in.Message)
+	if message == "" {
+		return "", fmt.Errorf("git_commit: message is required")
+	}
+	subject, _, _ := strings.Cut(message, "\n")
+	summary := fmt.Sprintf("commit %q", subject)
+	if len(in.Paths) > 0 {
+		summary += " staging " + strings.Join(in.Paths, ", ")
+	}
+	if in.All {
+		summary += " with all tracked changes"
+	}
+	if err := requireApproval(ApprovalRequest{Tool: "git_commit", Summary: summary}); err != nil {
+		return "", err
+	}
+	if len(in.Paths) > 0 {
+		if _, err := runGit(append([]string{"add", "--"}, in.Paths...)...); err != nil {
+			return "", err
+		}
+	}
+	args := []string{"commit", "-m", message}
+	if in.All {
+		args = append(args, "-a")
+	}
+	if _, err := runGit(args...); err != nil {
+		return "", err
+	}
+	out, err := runGit("show", "--no-color", "--stat", "--date=short", "--pretty=format:%h %ad %an: %s", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return "Committed " + capOutput(out, 0), nil
+}
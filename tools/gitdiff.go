@@ -0,0 +1,63 @@
+// Package tools provides the git_diff tool for the agent.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GitDiffDefinition is the tool that shows a git diff of the working tree, the index, or against a ref.
+var GitDiffDefinition = ToolDefinition{
+	Name:        "git_diff",
+	Description: "Show a git diff. By default compares the working tree with the index (unstaged changes); set staged for index vs HEAD, or ref to compare the working tree (or index, with staged) against a commit, branch or range like main...HEAD. Optionally limit to paths, or set stat for a per-file summary only. Output is capped; narrow by path if truncated.",
+	InputSchema: GitDiffInputSchema,
+	Function:    GitDiff,
+}
+
+// GitDiffInput is the JSON shape for the git_diff tool.
+type GitDiffInput struct {
+	Paths        []string `json:"paths" jsonschema_description:"Optional paths to limit the diff to."`
+	Staged       bool     `json:"staged" jsonschema_description:"If true, diff the index (staged changes) instead of the working tree."`
+	Ref          string   `json:"ref" jsonschema_description:"Optional commit, branch or range (e.g. HEAD~3, main...HEAD) to diff against."`
+	Stat         bool     `json:"stat" jsonschema_description:"If true, return only the per-file change summary (--stat)."`
+	ContextLines int      `json:"contextLines" jsonschema_description:"Optional number of context lines around each change; 0 or omit means git's default (3)."`
+	MaxChars     int      `json:"maxChars" jsonschema_description:"Optional cap on returned characters; 0 or omit means 20000."`
+}
+
+// GitDiffInputSchema is the Anthropic tool input schema for git_diff.
+var GitDiffInputSchema = GenerateSchema[GitDiffInput]()
+
+// GitDiff implements the git_diff tool: runs git diff with the requested options and caps the output.
+func GitDiff(input json.RawMessage) (string, error) {
+	var in GitDiffInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("git_diff input: %w", err)
+	}
+	args := []string{"diff", "--no-color", "--no-ext-diff"}
+	if in.Staged {
+		args = append(args, "--cached")
+	}
+	if in.Stat {
+		args = append(args, "--stat")
+	}
+	if in.ContextLines > 0 {
+		args = append(args, "-U"+strconv.Itoa(in.ContextLines))
+	}
+	if ref := strings.TrimSpace(in.Ref); ref != "" {
+		if strings.HasPrefix(ref, "-") {
+			return "", fmt.Errorf("git_diff: invalid ref %q", ref)
+		}
+		args = append(args, ref)
+	}
+	args = append(append(args, "--"), in.Paths...)
+	out, err := runGit(args...)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(out) == "" {
+		return "No differences.", nil
+	}
+	return capOutput(out, in.MaxChars), nil
+}
@@ -0,0 +1,123 @@
+// Package tools provides the git_log and git_show tools for the agent.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GitLogDefinition is the tool that lists commits with optional filters.
+var GitLogDefinition = ToolDefinition{
+	Name:        "git_log",
+	Description: "List commits, one per line as: short hash, date, author, subject. Filter by ref, path, author, date range or a message pattern. Use git_show to see a single commit.",
+	InputSchema: GitLogInputSchema,
+	Function:    GitLog,
+}
+
+// GitLogInput is the JSON shape for the git_log tool.
+type GitLogInput struct {
+	Ref      string `json:"ref" jsonschema_description:"Optional commit, branch or range to list (default HEAD)."`
+	Path     string `json:"path" jsonschema_description:"Optional path; only commits touching it are listed (follows renames for a single file)."`
+	Author   string `json:"author" jsonschema_description:"Optional author name or email pattern."`
+	Since    string `json:"since" jsonschema_description:"Optional lower date bound (e.g. 2024-01-31 or '2 weeks ago')."`
+	Until    string `json:"until" jsonschema_description:"Optional upper date bound."`
+	Grep     string `json:"grep" jsonschema_description:"Optional pattern matched against commit messages."`
+	MaxCount int    `json:"maxCount" jsonschema_description:"Optional maximum number of commits; 0 or omit means 20."`
+}
+
+// GitLogInputSchema is the Anthropic tool input schema for git_log.
+var GitLogInputSchema = GenerateSchema[GitLogInput]()
+
+const defaultGitLogMax = 20
+
+// GitLog implements the git_log tool: runs git log with a compact one-line format.
+func GitLog(input json.RawMessage) (string, error) {
+	var in GitLogInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("git_log input: %w", err)
+	}
+	maxCount := in.MaxCount
+	if maxCount <= 0 {
+		maxCount = defaultGitLogMax
+	}
+	args := []string{"log", "--no-color", "--date=short", "--pretty=format:%h %ad %an: %s", "-n", strconv.Itoa(maxCount)}
+	if in.Author != "" {
+		args = append(args, "--author="+in.Author)
+	}
+	if in.Since != "" {
+		args = append(args, "--since="+in.Since)
+	}
+	if in.Until != "" {
+		args = append(args, "--until="+in.Until)
+	}
+	if in.Grep != "" {
+		args = append(args, "--regexp-ignore-case", "--grep="+in.Grep)
+	}
+	if ref := strings.TrimSpace(in.Ref); ref != "" {
+		if strings.HasPrefix(ref, "-") {
+			return "", fmt.Errorf("git_log: invalid ref %q", ref)
+		}
+		args = append(args, ref)
+	}
+	if in.Path != "" {
+		args = append(args, "--follow", "--", in.Path)
+	}
+	out, err := runGit(args...)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(out) == "" {
+		return "No commits match.", nil
+	}
+	return capOutput(out, 0), nil
+}
+
+// GitShowDefinition is the tool that shows one commit, or a file's contents at a commit.
+var GitShowDefinition = ToolDefinition{
+	Name:        "git_show",
+	Description: "Show a commit's metadata and diff (or only its per-file summary with stat). If path is given, return that file's contents as of the commit instead. Output is capped.",
+	InputSchema: GitShowInputSchema,
+	Function:    GitShow,
+}
+
+// GitShowInput is the JSON shape for the git_show tool.
+type GitShowInput struct {
+	Ref      string `json:"ref" jsonschema_description:"Commit, branch or tag to show; default HEAD."`
+	Path     string `json:"path" jsonschema_description:"Optional file path; if set, returns the file contents at ref."`
+	Stat     bool   `json:"stat" jsonschema_description:"If true, show only the commit header and per-file summary."`
+	MaxChars int    `json:"maxChars" jsonschema_description:"Optional cap on returned characters; 0 or omit means 20000."`
+}
+
+// GitShowInputSchema is the Anthropic tool input schema for git_show.
+var GitShowInputSchema = GenerateSchema[GitShowInput]()
+
+// GitShow implements the git_show tool.
+func GitShow(input json.RawMessage) (string, error) {
+	var in GitShowInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("git_show input: %w", err)
+	}
+	ref := strings.TrimSpace(in.Ref)
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if strings.HasPrefix(ref, "-") {
+		return "", fmt.Errorf("git_show: invalid ref %q", ref)
+	}
+	var args []string
+	switch {
+	case in.Path != "":
+		args = []string{"show", ref + ":" + strings.TrimPrefix(in.Path, "./")}
+	case in.Stat:
+		args = []string{"show", "--no-color", "--stat", "--date=iso", ref}
+	default:
+		args = []string{"show", "--no-color", "--no-ext-diff", "--date=iso", ref}
+	}
+	out, err := runGit(args...)
+	if err != nil {
+		return "", err
+	}
+	return capOutput(out, in.MaxChars), nil
+}
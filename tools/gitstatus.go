@@ -0,0 +1,146 @@
+// Package tools provides the git_status tool for the agent.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// GitStatusDefinition is the tool that reports the repository status parsed from porcelain v2 output.
+var GitStatusDefinition = ToolDefinition{
+	Name:        "git_status",
+	Description: "Show the git status of the working tree: current branch, upstream ahead/behind counts, and files grouped as staged, unstaged, untracked and conflicted. Prefer this over running git status via runCommand.",
+	InputSchema: GitStatusInputSchema,
+	Function:    GitStatus,
+}
+
+// GitStatusInput is the JSON shape for the git_status tool.
+type GitStatusInput struct {
+	Paths         []string `json:"paths" jsonschema_description:"Optional paths to limit the status to."`
+	ShowUntracked bool     `json:"showUntracked" jsonschema_description:"If true, list untracked files individually; otherwise only their count is shown."`
+}
+
+// GitStatusInputSchema is the Anthropic tool input schema for git_status.
+var GitStatusInputSchema = GenerateSchema[GitStatusInput]()
+
+// gitStatusCodes maps porcelain status letters to short words.
+var gitStatusCodes = map[byte]string{
+	'M': "modified",
+	'T': "type-changed",
+	'A': "added",
+	'D': "deleted",
+	'R': "renamed",
+	'C': "copied",
+	'U': "unmerged",
+}
+
+// GitStatus implements the git_status tool: runs git status --porcelain=v2 --branch -z and summarizes it.
+func GitStatus(input json.RawMessage) (string, error) {
+	var in GitStatusInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("git_status input: %w", err)
+	}
+	args := []string{"status", "--porcelain=v2", "--branch", "-z"}
+	if len(in.Paths) > 0 {
+		args = append(append(args, "--"), in.Paths...)
+	}
+	out, err := runGit(args...)
+	if err != nil {
+		return "", err
+	}
+
+	var head, oid, upstream, ab string
+	var staged, unstaged, untracked, conflicts []string
+	fields := strings.Split(out, "\x00")
+	for i := 0; i < len(fields); i++ {
+		entry := fields[i]
+		if entry == "" {
+			continue
+		}
+		switch entry[0] {
+		case '#':
+			parts := strings.SplitN(entry, " ", 3)
+			if len(parts) < 3 {
+				continue
+			}
+			switch parts[1] {
+			case "branch.head":
+				head = parts[2]
+			case "branch.oid":
+				oid = parts[2]
+			case "branch.upstream":
+				upstream = parts[2]
+			case "branch.ab":
+				ab = parts[2]
+			}
+		case '1', '2':
+			n := 9
+			if entry[0] == '2' {
+				n = 10
+			}
+			parts := strings.SplitN(entry, " ", n)
+			if len(parts) < n {
+				continue
+			}
+			xy, path := parts[1], parts[n-1]
+			if entry[0] == '2' && i+1 < len(fields) {
+				// With -z the original path of a rename or copy follows as its own field.
+				i++
+				path = fields[i] + " -> " + path
+			}
+			if xy[0] != '.' {
+				staged = append(staged, fmt.Sprintf("%s %s", gitStatusCodes[xy[0]], path))
+			}
+			if xy[1] != '.' {
+				unstaged = append(unstaged, fmt.Sprintf("%s %s", gitStatusCodes[xy[1]], path))
+			}
+		case 'u':
+			parts := strings.SplitN(entry, " ", 11)
+			if len(parts) == 11 {
+				conflicts = append(conflicts, fmt.Sprintf("%s %s", parts[1], parts[10]))
+			}
+		case '?':
+			untracked = append(untracked, strings.TrimPrefix(entry, "? "))
+		}
+	}
+
+	var b strings.Builder
+	branch := head
+	if head == "(detached)" && len(oid) >= 7 {
+		branch = "detached at " + oid[:7]
+	}
+	fmt.Fprintf(&b, "branch: %s", branch)
+	if upstream != "" {
+		fmt.Fprintf(&b, " (upstream %s", upstream)
+		if ab != "" {
+			counts := strings.Fields(ab)
+			if len(counts) == 2 {
+				fmt.Fprintf(&b, ", ahead %s, behind %s", strings.TrimPrefix(counts[0], "+"), strings.TrimPrefix(counts[1], "-"))
+			}
+		}
+		b.WriteString(")")
+	}
+	b.WriteString("\n")
+	writeGroup := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "%s (%d):\n", title, len(items))
+		for _, item := range items {
+			fmt.Fprintf(&b, "  %s\n", item)
+		}
+	}
+	writeGroup("conflicts", conflicts)
+	writeGroup("staged", staged)
+	writeGroup("unstaged", unstaged)
+	if in.ShowUntracked {
+		writeGroup("untracked", untracked)
+	} else if len(untracked) > 0 {
+		fmt.Fprintf(&b, "untracked: %d file(s) (set showUntracked to list them)\n", len(untracked))
+	}
+	if len(staged)+len(unstaged)+len(untracked)+len(conflicts) == 0 {
+		b.WriteString("working tree clean\n")
+	}
+	return capOutput(strings.TrimRight(b.String(), "\n"), 0), nil
+}
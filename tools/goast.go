@@ -0,0 +1,138 @@
+// Package tools provides shared go/parser helpers for the go_* tools.
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// parseGoSource parses path, which is either a .go file or a package directory, with comments.
+// For a directory the .go files in the current build (see build.Default.MatchFile) are parsed,
+// skipping _test.go files unless includeTests is set; a file named explicitly is always parsed.
+func parseGoSource(path string, includeTests bool) (*token.FileSet, []*ast.File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	fset := token.NewFileSet()
+	if !info.IsDir() {
+		if !strings.HasSuffix(path, ".go") {
+			return nil, nil, fmt.Errorf("%s is not a .go file", path)
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, err
+		}
+		return fset, []*ast.File{f}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var files []*ast.File
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		if strings.HasSuffix(name, "_test.go") && !includeTests {
+			continue
+		}
+		if ok, err := build.Default.MatchFile(path, name); err != nil || !ok {
+			continue // excluded by its name or build constraints, e.g. x_windows.go on Linux
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(path, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no Go files in %s", path)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return fset.Position(files[i].Package).Filename < fset.Position(files[j].Package).Filename
+	})
+	return fset, files, nil
+}
+
+// nodeString prints node as gofmt would.
+func nodeString(fset *token.FileSet, node any) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// docSummary returns the first line of a doc comment, or "".
+func docSummary(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	text := strings.TrimSpace(doc.Text())
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return text
+}
+
+// receiverTypeName returns the base type name of a method's receiver ("Agent" for (a *Agent) or
+// (l *List[T])), or "" for plain functions.
+func receiverTypeName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return ""
+	}
+	expr := fd.Recv.List[0].Type
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// funcSignature renders a function declaration without its body or doc comment.
+func funcSignature(fset *token.FileSet, fd *ast.FuncDecl) string {
+	sig := *fd
+	sig.Body = nil
+	sig.Doc = nil
+	return nodeString(fset, &sig)
+}
+
+// lineRange renders the file and line span of node, e.g. "main.go:12-30".
+func lineRange(fset *token.FileSet, node ast.Node) string {
+	start := fset.Position(node.Pos())
+	end := fset.Position(node.End())
+	name := filepath.Base(start.Filename)
+	if start.Line == end.Line {
+		return fmt.Sprintf("%s:%d", name, start.Line)
+	}
+	return fmt.Sprintf("%s:%d-%d", name, start.Line, end.Line)
+}
+
+// joinIdents renders a list of identifiers as "a, b, c".
+func joinIdents(idents []*ast.Ident) string {
+	names := make([]string, len(idents))
+	for i, id := range idents {
+		names[i] = id.Name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,34 @@
+package tools
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParseGoSourceSkipsExcludedFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the constrained file is part of the build on windows")
+	}
+	writeModule(t, map[string]string{
+		"p.go":         "package p\n\nfunc open() int { return 1 }\n",
+		"p_windows.go": "package p\n\nfunc open() int { return 2 }\n",
+		"gen.go":       "//go:build ignore\n\npackage main\n",
+	})
+	fset, files, err := parseGoSource(".", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(fset.Position(f.Package).Filename))
+	}
+	if len(names) != 1 || names[0] != "p.go" {
+		t.Errorf("parsed %v, want only p.go", names)
+	}
+
+	// A file named explicitly is parsed whatever its constraints.
+	if _, files, err := parseGoSource("p_windows.go", false); err != nil || len(files) != 1 {
+		t.Errorf("parsing p_windows.go: %d files, %v", len(files), err)
+	}
+}
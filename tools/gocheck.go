@@ -0,0 +1,221 @@
+// Package tools provides the go_check tool for the agent.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GoCheckDefinition is the tool that runs go build and go vet and returns structured diagnostics.
+var GoCheckDefinition = ToolDefinition{
+	Name:        "go_check",
+	Description: "Run go build and go vet for the given packages and return deduplicated diagnostics (file, line, column, severity, message) grouped by file, each with a few lines of source context, so all errors can be fixed in one pass. Compiler errors are severity error, vet findings are warning. Prefer this over runCommand for checking Go code.",
+	InputSchema: GoCheckInputSchema,
+	Function:    GoCheck,
+}
+
+// GoCheckInput is the JSON shape for the go_check tool.
+type GoCheckInput struct {
+	Packages     []string `json:"packages" jsonschema_description:"Package patterns to check (e.g. ./..., ./tools); default ./...."`
+	SkipVet      bool     `json:"skipVet" jsonschema_description:"If true, only run go build."`
+	ContextLines int      `json:"contextLines" jsonschema_description:"Source lines shown before and after each diagnostic; default 2, -1 for none."`
+	WorkingDir   string   `json:"workingDir" jsonschema_description:"Optional directory to run in; default is the current directory."`
+}
+
+// GoCheckInputSchema is the Anthropic tool input schema for go_check.
+var GoCheckInputSchema = GenerateSchema[GoCheckInput]()
+
+const defaultGoCheckContext = 2
+
+// diagnostic is one compiler, vet or linter finding.
+type diagnostic struct {
+	file     string
+	line     int
+	column   int
+	severity string
+	source   string // tool that reported it, e.g. build, vet, or a linter name
+	message  string
+}
+
+// diagnosticLine matches "file.go:12:5: message" (column optional), with an optional "vet: " prefix.
+var diagnosticLine = regexp.MustCompile(`^(?:vet: )?(\S+?\.go):(\d+)(?::(\d+))?: (.*)$`)
+
+// GoCheck implements the go_check tool: runs go build then go vet and merges their diagnostics.
+func GoCheck(input json.RawMessage) (string, error) {
+	var goCheckInput GoCheckInput
+	if err := json.Unmarshal(input, &goCheckInput); err != nil {
+		return "", fmt.Errorf("go_check input: %w", err)
+	}
+	packages := goCheckInput.Packages
+	if len(packages) == 0 {
+		packages = []string{"./..."}
+	}
+	contextLines := goCheckInput.ContextLines
+	if contextLines == 0 {
+		contextLines = defaultGoCheckContext
+	}
+	workDir := goCheckInput.WorkingDir
+
+	buildOut, buildErr := runGoTool(workDir, append([]string{"build", "-o", os.DevNull}, packages...)...)
+	if buildErr != nil {
+		return "", fmt.Errorf("go_check: %w", buildErr)
+	}
+	diags, other := parseDiagnostics(buildOut, workDir, "build", "error")
+	status := []string{fmt.Sprintf("go build: %s", countLabel(len(diags), "error"))}
+	if !goCheckInput.SkipVet {
+		vetOut, vetErr := runGoTool(workDir, append([]string{"vet"}, packages...)...)
+		if vetErr != nil {
+			return "", fmt.Errorf("go_check: %w", vetErr)
+		}
+		vetDiags, vetOther := parseDiagnostics(vetOut, workDir, "vet", "warning")
+		before := len(diags)
+		diags = mergeDiagnostics(diags, vetDiags)
+		other = append(other, vetOther...)
+		status = append(status, fmt.Sprintf("go vet: %s", countLabel(len(diags)-before, "finding")))
+	}
+	result := strings.Join(status, ", ")
+	if len(diags) > 0 {
+		result += "\n\n" + formatDiagnostics(diags, contextLines)
+	}
+	if len(other) > 0 {
+		result += "\n\nOther output:\n" + strings.Join(dedupe(other), "\n")
+	}
+	if len(diags) == 0 && len(other) == 0 {
+		result += "\nNo problems found."
+	}
+	return capOutput(result, 0), nil
+}
+
+// runGoTool runs the go command in dir and returns its combined output. A non-zero exit is not an
+// error, since it just means diagnostics were reported; failing to start go is.
+func runGoTool(dir string, args ...string) (string, error) {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// countLabel renders "no errors", "1 error" or "3 errors".
+func countLabel(n int, noun string) string {
+	switch n {
+	case 0:
+		return "no " + noun + "s"
+	case 1:
+		return "1 " + noun
+	default:
+		return fmt.Sprintf("%d %ss", n, noun)
+	}
+}
+
+// parseDiagnostics extracts file:line:col diagnostics from go command output. Indented lines continue
+// the previous message; "# package" headers are dropped; anything else is returned as other output.
+func parseDiagnostics(output, workDir, source, severity string) ([]diagnostic, []string) {
+	var diags []diagnostic
+	var other []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		if (strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "    ")) && len(diags) > 0 {
+			diags[len(diags)-1].message += "\n" + strings.TrimSpace(line)
+			continue
+		}
+		m := diagnosticLine.FindStringSubmatch(line)
+		if m == nil {
+			other = append(other, line)
+			continue
+		}
+		d := diagnostic{file: diagnosticPath(m[1], workDir), severity: severity, source: source, message: m[4]}
+		d.line, _ = strconv.Atoi(m[2])
+		d.column, _ = strconv.Atoi(m[3])
+		diags = append(diags, d)
+	}
+	return diags, other
+}
+
+// diagnosticPath makes a path reported by a tool run in workDir relative to the working directory.
+func diagnosticPath(path, workDir string) string {
+	if !filepath.IsAbs(path) && workDir != "" {
+		path = filepath.Join(workDir, path)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return displayPath(abs)
+	}
+	return filepath.Clean(path)
+}
+
+// mergeDiagnostics appends extra to diags, dropping entries already reported at the same position
+// with the same message (go vet repeats compile errors it hits while type-checking).
+func mergeDiagnostics(diags, extra []diagnostic) []diagnostic {
+	seen := map[string]bool{}
+	key := func(d diagnostic) string {
+		return fmt.Sprintf("%s:%d:%d:%s", d.file, d.line, d.column, d.message)
+	}
+	for _, d := range diags {
+		seen[key(d)] = true
+	}
+	for _, d := range extra {
+		if !seen[key(d)] {
+			seen[key(d)] = true
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
+
+// formatDiagnostics groups diagnostics by file, sorted by position, each followed by source context.
+func formatDiagnostics(diags []diagnostic, contextLines int) string {
+	diags = mergeDiagnostics(nil, diags)
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		if a.file != b.file {
+			return a.file < b.file
+		}
+		if a.line != b.line {
+			return a.line < b.line
+		}
+		return a.column < b.column
+	})
+	var b strings.Builder
+	var lines []string
+	current := ""
+	for _, d := range diags {
+		if d.file != current {
+			if current != "" {
+				b.WriteString("\n")
+			}
+			current = d.file
+			lines = nil
+			if content, err := os.ReadFile(d.file); err == nil {
+				lines = strings.Split(string(content), "\n")
+			}
+			b.WriteString(d.file + "\n")
+		}
+		pos := strconv.Itoa(d.line)
+		if d.column > 0 {
+			pos += ":" + strconv.Itoa(d.column)
+		}
+		fmt.Fprintf(&b, "  %s %s (%s): %s\n", pos, d.severity, d.source, strings.ReplaceAll(d.message, "\n", "\n      "))
+		if contextLines < 0 || lines == nil {
+			continue
+		}
+		for n := max(1, d.line-contextLines); n <= min(len(lines), d.line+contextLines); n++ {
+			marker := " "
+			if n == d.line {
+				marker = ">"
+			}
+			fmt.Fprintf(&b, "    %s %4d | %s\n", marker, n, lines[n-1])
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
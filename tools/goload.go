@@ -0,0 +1,460 @@
+// Package tools provides the go/types module loader behind the Go navigation tools.
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// goModule type-checks the packages of one Go module from source. Imports from outside the module
+// (standard library and dependencies) are read from compiler export data located with
+// `go list -export`, which works offline from the build cache.
+type goModule struct {
+	root     string // directory containing go.mod
+	path     string // module path
+	fset     *token.FileSet
+	pkgs     map[string]*goPackage // module packages by import path; external test packages end in _test
+	loading  map[string]bool
+	exports  map[string]string // import path -> export data file
+	external types.Importer
+	overlay  map[string][]byte // absolute file name -> content used instead of the file on disk
+}
+
+// goPackage is one type-checked module package.
+type goPackage struct {
+	path   string
+	dir    string
+	files  []*ast.File
+	types  *types.Package
+	info   *types.Info
+	errors []error
+}
+
+// loadGoModule finds the go.mod at or above dir and prepares a loader for its module.
+func loadGoModule(dir string) (*goModule, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	root := findGoModDir(abs)
+	if root == "" {
+		return nil, fmt.Errorf("no go.mod found at or above %s", abs)
+	}
+	modPath, err := readModulePath(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	m := newGoModule(root, modPath, map[string]string{}, nil)
+	m.findExports()
+	return m, nil
+}
+
+// newGoModule returns an empty loader for the module at root.
+func newGoModule(root, modPath string, exports map[string]string, overlay map[string][]byte) *goModule {
+	m := &goModule{
+		root:    root,
+		path:    modPath,
+		fset:    token.NewFileSet(),
+		pkgs:    map[string]*goPackage{},
+		loading: map[string]bool{},
+		exports: exports,
+		overlay: overlay,
+	}
+	m.external = importer.ForCompiler(m.fset, "gc", func(path string) (io.ReadCloser, error) {
+		file, ok := m.exports[path]
+		if !ok || file == "" {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(file)
+	})
+	return m
+}
+
+// withOverlay returns a fresh loader for the same module that reads the given files from memory
+// instead of disk, reusing the export data already located.
+func (m *goModule) withOverlay(overlay map[string][]byte) *goModule {
+	return newGoModule(m.root, m.path, m.exports, overlay)
+}
+
+// findGoModDir returns the nearest ancestor of dir (inclusive) containing go.mod, or "" if there is none.
+func findGoModDir(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// readModulePath returns the module path declared in a go.mod file.
+func readModulePath(gomod string) (string, error) {
+	f, err := os.Open(gomod)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if rest, ok := strings.CutPrefix(line, "module"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			return strings.Trim(strings.TrimSpace(rest), `"`), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no module directive", gomod)
+}
+
+// findExports asks the go command for the export data of every dependency, including test-only
+// ones. Failures leave the map partial; imports without export data are reported as type errors.
+func (m *goModule) findExports() {
+	cmd := exec.Command("go", "list", "-e", "-test", "-export", "-deps", "-f", "{{.ImportPath}}\t{{.Export}}", "./...")
+	cmd.Dir = m.root
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	_ = cmd.Run()
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		path, file, ok := strings.Cut(line, "\t")
+		if !ok || strings.Contains(path, " ") {
+			continue // test variants such as "p [p.test]"
+		}
+		m.exports[path] = file
+	}
+}
+
+// inModule reports whether importPath belongs to the module.
+func (m *goModule) inModule(importPath string) bool {
+	return importPath == m.path || strings.HasPrefix(importPath, m.path+"/")
+}
+
+// Import implements types.Importer: module packages are checked from source, others come from export data.
+func (m *goModule) Import(path string) (*types.Package, error) {
+	if !m.inModule(path) {
+		return m.external.Import(path)
+	}
+	pkg, err := m.load(path)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.types, nil
+}
+
+// dirFor returns the directory of a module import path.
+func (m *goModule) dirFor(importPath string) string {
+	rel := strings.TrimPrefix(strings.TrimPrefix(importPath, m.path), "/")
+	return filepath.Join(m.root, filepath.FromSlash(rel))
+}
+
+// importPathFor returns the import path of a directory inside the module.
+func (m *goModule) importPathFor(dir string) (string, error) {
+	rel, err := filepath.Rel(m.root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside module %s", dir, m.root)
+	}
+	if rel == "." {
+		return m.path, nil
+	}
+	return m.path + "/" + filepath.ToSlash(rel), nil
+}
+
+// load type-checks the module package with the given import path (including its in-package
+// _test.go files) or, for a path ending in _test, the directory's external test package.
+func (m *goModule) load(importPath string) (*goPackage, error) {
+	if pkg, ok := m.pkgs[importPath]; ok {
+		return pkg, nil
+	}
+	if m.loading[importPath] {
+		return nil, fmt.Errorf("import cycle through %s", importPath)
+	}
+	m.loading[importPath] = true
+	defer delete(m.loading, importPath)
+
+	basePath, external := strings.CutSuffix(importPath, "_test")
+	if external && m.pkgs[basePath] == nil {
+		if _, err := m.load(basePath); err != nil {
+			return nil, err
+		}
+	}
+	dir := m.dirFor(basePath)
+	files, err := m.parseDir(dir, external)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files for %s in %s", importPath, dir)
+	}
+	pkg := &goPackage{
+		path:  importPath,
+		dir:   dir,
+		files: files,
+		info: &types.Info{
+			Defs:       map[*ast.Ident]types.Object{},
+			Uses:       map[*ast.Ident]types.Object{},
+			Implicits:  map[ast.Node]types.Object{},
+			Selections: map[*ast.SelectorExpr]*types.Selection{},
+			Types:      map[ast.Expr]types.TypeAndValue{},
+		},
+	}
+	conf := types.Config{
+		Importer:    m,
+		FakeImportC: true,
+		Error:       func(err error) { pkg.errors = append(pkg.errors, err) },
+	}
+	// Errors are collected above; the partially checked package is still useful for navigation.
+	pkg.types, _ = conf.Check(importPath, m.fset, files, pkg.info)
+	m.pkgs[importPath] = pkg
+	return pkg, nil
+}
+
+// parseDir parses the .go files in dir that match the current build context. With external false it
+// returns the package's files plus in-package tests; with external true only the package_test files.
+func (m *goModule) parseDir(dir string, external bool) ([]*ast.File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		if ok, err := build.Default.MatchFile(dir, name); err != nil || !ok {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		var src any
+		if content, ok := m.overlay[path]; ok {
+			src = content
+		}
+		f, err := parser.ParseFile(m.fset, path, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		isExternal := strings.HasSuffix(name, "_test.go") && strings.HasSuffix(f.Name.Name, "_test")
+		if isExternal == external {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// loadAll loads every package in the module, including external test packages.
+func (m *goModule) loadAll() error {
+	return m.walkPackageDirs(func(dir string) error {
+		importPath, err := m.importPathFor(dir)
+		if err != nil {
+			return nil
+		}
+		if _, err := m.load(importPath); err != nil {
+			return nil // directory without Go files
+		}
+		if files, err := m.parseDir(dir, true); err == nil && len(files) > 0 {
+			if _, err := m.load(importPath + "_test"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// walkPackageDirs calls fn for the module root and every directory below it that can hold one of
+// its packages. Nested modules, testdata, vendor, hidden and junk directories are skipped.
+func (m *goModule) walkPackageDirs(fn func(dir string) error) error {
+	return filepath.WalkDir(m.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if path != m.root {
+			if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor" || junkDirs[name] {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+		return fn(path)
+	})
+}
+
+// constrainedMentions returns the positions of identifiers called name in the module's .go files
+// that parseDir leaves out because of build constraints (other GOOS/GOARCH, build tags). Those
+// files are not type-checked, so a mention there may or may not refer to the same object.
+func (m *goModule) constrainedMentions(name string) ([]token.Position, error) {
+	var mentions []token.Position
+	fset := token.NewFileSet()
+	err := m.walkPackageDirs(func(dir string) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil
+		}
+		for _, e := range entries {
+			file := e.Name()
+			if e.IsDir() || !strings.HasSuffix(file, ".go") {
+				continue
+			}
+			if ok, err := build.Default.MatchFile(dir, file); err != nil || ok {
+				continue
+			}
+			path := filepath.Join(dir, file)
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if !bytes.Contains(content, []byte(name)) {
+				continue
+			}
+			f, err := parser.ParseFile(fset, path, content, parser.SkipObjectResolution)
+			if err != nil {
+				return err
+			}
+			ast.Inspect(f, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && id.Name == name {
+					mentions = append(mentions, fset.Position(id.Pos()))
+				}
+				return true
+			})
+		}
+		return nil
+	})
+	return mentions, err
+}
+
+// packageForFile loads the package containing the Go file at path and returns it with the parsed file.
+func (m *goModule) packageForFile(path string) (*goPackage, *ast.File, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	importPath, err := m.importPathFor(filepath.Dir(abs))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, candidate := range []string{importPath, importPath + "_test"} {
+		pkg, err := m.load(candidate)
+		if err != nil {
+			continue
+		}
+		for _, f := range pkg.files {
+			if m.fset.Position(f.Package).Filename == abs {
+				return pkg, f, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("%s is not part of a package in module %s (excluded by build constraints?)", path, m.path)
+}
+
+// sortedPackages returns the loaded module packages ordered by import path.
+func (m *goModule) sortedPackages() []*goPackage {
+	pkgs := make([]*goPackage, 0, len(m.pkgs))
+	for _, pkg := range m.pkgs {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].path < pkgs[j].path })
+	return pkgs
+}
+
+// identAt returns the identifier in f covering the 1-based line and byte column. When column is
+// 0, name locates the identifier on the line instead.
+func (m *goModule) identAt(f *ast.File, line, column int, name string) (*ast.Ident, error) {
+	tf := m.fset.File(f.Package)
+	if line < 1 || line > tf.LineCount() {
+		return nil, fmt.Errorf("line %d is outside %s (%d lines)", line, tf.Name(), tf.LineCount())
+	}
+	var found *ast.Ident
+	var candidates []*ast.Ident
+	lineStart := tf.LineStart(line)
+	ast.Inspect(f, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || tf.Line(id.Pos()) != line {
+			return true
+		}
+		col := int(id.Pos()-lineStart) + 1
+		if column > 0 && col <= column && column <= col+len(id.Name) {
+			found = id
+		}
+		if column <= 0 && id.Name == name {
+			candidates = append(candidates, id)
+		}
+		return true
+	})
+	if column <= 0 {
+		if name == "" {
+			return nil, fmt.Errorf("column or name is required")
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("no identifier %q on %s:%d", name, tf.Name(), line)
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].Pos() < candidates[j].Pos() })
+		return candidates[0], nil
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no identifier at %s:%d:%d", tf.Name(), line, column)
+	}
+	return found, nil
+}
+
+// objectOf returns the object an identifier defines or refers to.
+func (pkg *goPackage) objectOf(id *ast.Ident) types.Object {
+	if obj := pkg.info.Defs[id]; obj != nil {
+		return obj
+	}
+	return pkg.info.Uses[id]
+}
+
+// objectKey identifies an object across packages: by declaration position when it has one
+// (objects from export data keep their source positions), otherwise by package and name.
+func (m *goModule) objectKey(obj types.Object) string {
+	if obj.Pos().IsValid() {
+		pos := m.fset.Position(obj.Pos())
+		return fmt.Sprintf("%s:%d:%d:%s", pos.Filename, pos.Line, pos.Column, obj.Name())
+	}
+	if obj.Pkg() != nil {
+		return obj.Pkg().Path() + "." + obj.Name()
+	}
+	return "builtin." + obj.Name()
+}
+
+// sourceLine returns the trimmed text of the line at pos, or "" if the file cannot be read.
+func (m *goModule) sourceLine(pos token.Position) string {
+	content, err := os.ReadFile(pos.Filename)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(content), "\n")
+	if pos.Line < 1 || pos.Line > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[pos.Line-1])
+}
+
+// qualifierFor renders package names relative to pkg in type strings.
+func qualifierFor(pkg *types.Package) types.Qualifier {
+	return func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		return other.Name()
+	}
+}
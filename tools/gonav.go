@@ -0,0 +1,301 @@
+// Package tools provides the go_definition, go_references and go_implementations tools for the agent.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GoPositionInput is the JSON shape shared by the Go navigation tools: an identifier in a Go file.
+type GoPositionInput struct {
+	Path   string `json:"path" jsonschema_description:"The .go file containing the identifier, relative to the working directory."`
+	Line   int    `json:"line" jsonschema_description:"1-based line of the identifier."`
+	Column int    `json:"column" jsonschema_description:"1-based byte column of the identifier (a tab counts as one). Optional if name is given."`
+	Name   string `json:"name" jsonschema_description:"Optional identifier name; used instead of column to pick the first identifier with this name on the line."`
+}
+
+// GoReferencesInput is the JSON shape for the go_references tool.
+type GoReferencesInput struct {
+	GoPositionInput
+	MaxResults int `json:"maxResults" jsonschema_description:"Optional cap on references listed; 0 or omit means 200."`
+}
+
+// GoDefinitionDefinition is the tool that jumps from an identifier to its declaration.
+var GoDefinitionDefinition = ToolDefinition{
+	Name:        "go_definition",
+	Description: "Go to the definition of the Go identifier at path:line:column (or the identifier called name on that line), using type-checked information for the whole module, so identically named identifiers in different packages are told apart. Returns the declaration's kind and signature, its file:line:column and source line. Works offline; dependencies and the standard library are resolved from the build cache.",
+	InputSchema: GoPositionInputSchema,
+	Function:    GoDefinition,
+}
+
+// GoReferencesDefinition is the tool that lists every use of a Go identifier's object across the module.
+var GoReferencesDefinition = ToolDefinition{
+	Name:        "go_references",
+	Description: "Find all references in the module (tests included) to the Go object named by the identifier at path:line:column (or name on that line), using type information rather than text search. Results are grouped by file with line:column and the source line; the declaration is marked.",
+	InputSchema: GoReferencesInputSchema,
+	Function:    GoReferences,
+}
+
+// GoImplementationsDefinition is the tool that relates Go types and interfaces.
+var GoImplementationsDefinition = ToolDefinition{
+	Name:        "go_implementations",
+	Description: "For the Go type named by the identifier at path:line:column (or name on that line): if it is an interface, list the module's types that implement it (noting when only the pointer type does); otherwise list the interfaces it satisfies, from the module, the packages it imports, and error.",
+	InputSchema: GoPositionInputSchema,
+	Function:    GoImplementations,
+}
+
+// GoPositionInputSchema is the Anthropic tool input schema for go_definition and go_implementations.
+var GoPositionInputSchema = GenerateSchema[GoPositionInput]()
+
+// GoReferencesInputSchema is the Anthropic tool input schema for go_references.
+var GoReferencesInputSchema = GenerateSchema[GoReferencesInput]()
+
+const defaultGoReferencesMax = 200
+
+// resolveGoIdent loads the module around in.Path and returns the loader, the package and the
+// object the identifier at the given position refers to.
+func resolveGoIdent(tool string, in GoPositionInput) (*goModule, *goPackage, types.Object, error) {
+	if in.Path == "" || in.Line < 1 {
+		return nil, nil, nil, fmt.Errorf("%s: path and line are required", tool)
+	}
+	m, err := loadGoModule(filepath.Dir(in.Path))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", tool, err)
+	}
+	pkg, f, err := m.packageForFile(in.Path)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", tool, err)
+	}
+	id, err := m.identAt(f, in.Line, in.Column, in.Name)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", tool, err)
+	}
+	obj := pkg.objectOf(id)
+	if obj == nil {
+		return nil, nil, nil, fmt.Errorf("%s: no type information for %q at %s (it may be a label, a package clause, or in code with type errors)", tool, id.Name, m.fset.Position(id.Pos()))
+	}
+	return m, pkg, obj, nil
+}
+
+// GoDefinition implements the go_definition tool.
+func GoDefinition(input json.RawMessage) (string, error) {
+	var goDefinitionInput GoPositionInput
+	if err := json.Unmarshal(input, &goDefinitionInput); err != nil {
+		return "", fmt.Errorf("go_definition input: %w", err)
+	}
+	m, pkg, obj, err := resolveGoIdent("go_definition", goDefinitionInput)
+	if err != nil {
+		return "", err
+	}
+	desc := types.ObjectString(obj, qualifierFor(pkg.types))
+	if obj.Pkg() == nil {
+		return fmt.Sprintf("%s is predeclared (universe scope)", desc), nil
+	}
+	if !obj.Pos().IsValid() {
+		return fmt.Sprintf("%s\ndeclared in package %s (no source position available)", desc, obj.Pkg().Path()), nil
+	}
+	pos := m.fset.Position(obj.Pos())
+	location := fmt.Sprintf("%s:%d:%d", displayPath(pos.Filename), pos.Line, pos.Column)
+	if !m.inModule(obj.Pkg().Path()) {
+		location += fmt.Sprintf(" (package %s, outside the module)", obj.Pkg().Path())
+	}
+	return fmt.Sprintf("%s\n%s\n%d: %s", desc, location, pos.Line, m.sourceLine(pos)), nil
+}
+
+// GoReferences implements the go_references tool.
+func GoReferences(input json.RawMessage) (string, error) {
+	var goReferencesInput GoReferencesInput
+	if err := json.Unmarshal(input, &goReferencesInput); err != nil {
+		return "", fmt.Errorf("go_references input: %w", err)
+	}
+	maxResults := goReferencesInput.MaxResults
+	if maxResults <= 0 {
+		maxResults = defaultGoReferencesMax
+	}
+	m, pkg, obj, err := resolveGoIdent("go_references", goReferencesInput.GoPositionInput)
+	if err != nil {
+		return "", err
+	}
+	if err := m.loadAll(); err != nil {
+		return "", fmt.Errorf("go_references: %w", err)
+	}
+	target := m.objectKey(obj)
+	type reference struct {
+		pos  token.Position
+		decl bool
+	}
+	var refs []reference
+	for _, p := range m.sortedPackages() {
+		for id, o := range p.info.Defs {
+			if o != nil && m.objectKey(o) == target {
+				refs = append(refs, reference{m.fset.Position(id.Pos()), true})
+			}
+		}
+		for id, o := range p.info.Uses {
+			if m.objectKey(o) == target {
+				refs = append(refs, reference{m.fset.Position(id.Pos()), false})
+			}
+		}
+	}
+	desc := types.ObjectString(obj, qualifierFor(pkg.types))
+	if len(refs) == 0 {
+		return fmt.Sprintf("No references to %s in module %s", desc, m.path), nil
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i].pos, refs[j].pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+	files := map[string]bool{}
+	for _, r := range refs {
+		files[r.pos.Filename] = true
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d reference(s) to %s in %d file(s):", len(refs), desc, len(files))
+	current := ""
+	for i, r := range refs {
+		if i == maxResults {
+			fmt.Fprintf(&b, "\n[%d more references not shown; raise maxResults.]", len(refs)-maxResults)
+			break
+		}
+		if r.pos.Filename != current {
+			current = r.pos.Filename
+			b.WriteString("\n" + displayPath(current))
+		}
+		note := ""
+		if r.decl {
+			note = " (declaration)"
+		}
+		fmt.Fprintf(&b, "\n  %d:%d%s: %s", r.pos.Line, r.pos.Column, note, m.sourceLine(r.pos))
+	}
+	return b.String(), nil
+}
+
+// GoImplementations implements the go_implementations tool.
+func GoImplementations(input json.RawMessage) (string, error) {
+	var goImplementationsInput GoPositionInput
+	if err := json.Unmarshal(input, &goImplementationsInput); err != nil {
+		return "", fmt.Errorf("go_implementations input: %w", err)
+	}
+	m, pkg, obj, err := resolveGoIdent("go_implementations", goImplementationsInput)
+	if err != nil {
+		return "", err
+	}
+	typeName, ok := obj.(*types.TypeName)
+	if !ok {
+		return "", fmt.Errorf("go_implementations: %s is not a type; point at a type or interface name", obj.Name())
+	}
+	if err := m.loadAll(); err != nil {
+		return "", fmt.Errorf("go_implementations: %w", err)
+	}
+	qualify := qualifierFor(pkg.types)
+	named := typeName.Type()
+	var lines []string
+	if iface, ok := named.Underlying().(*types.Interface); ok {
+		if !iface.IsMethodSet() || iface.NumMethods() == 0 {
+			return "", fmt.Errorf("go_implementations: %s has no methods or is a constraint; every type (or its type set) satisfies it", typeName.Name())
+		}
+		for _, t := range m.declaredTypes(false) {
+			if types.Identical(t.Type(), named) {
+				continue
+			}
+			if _, isIface := t.Type().Underlying().(*types.Interface); isIface {
+				continue
+			}
+			switch {
+			case types.Implements(t.Type(), iface):
+				lines = append(lines, m.describeType(t, "", qualify))
+			case types.Implements(types.NewPointer(t.Type()), iface):
+				lines = append(lines, m.describeType(t, "*", qualify))
+			}
+		}
+		if len(lines) == 0 {
+			return fmt.Sprintf("No types in module %s implement %s", m.path, typeName.Name()), nil
+		}
+		return fmt.Sprintf("%d type(s) implement %s:\n%s", len(lines), types.TypeString(named, qualify), strings.Join(lines, "\n")), nil
+	}
+
+	for _, t := range m.declaredTypes(true) {
+		iface, ok := t.Type().Underlying().(*types.Interface)
+		if !ok || !iface.IsMethodSet() || iface.NumMethods() == 0 {
+			continue
+		}
+		switch {
+		case types.Implements(named, iface):
+			lines = append(lines, m.describeType(t, "", qualify))
+		case types.Implements(types.NewPointer(named), iface):
+			lines = append(lines, m.describeType(t, "", qualify)+"  (via *"+typeName.Name()+")")
+		}
+	}
+	if len(lines) == 0 {
+		return fmt.Sprintf("%s satisfies no non-empty interfaces in module %s or its imports", typeName.Name(), m.path), nil
+	}
+	return fmt.Sprintf("%s satisfies %d interface(s):\n%s", types.TypeString(named, qualify), len(lines), strings.Join(lines, "\n")), nil
+}
+
+// declaredTypes returns the non-generic package-level types declared in the module, sorted by
+// package and name. With withImports, types of directly imported packages and error are included.
+func (m *goModule) declaredTypes(withImports bool) []*types.TypeName {
+	seen := map[*types.Package]bool{}
+	var result []*types.TypeName
+	add := func(p *types.Package) {
+		if p == nil || seen[p] {
+			return
+		}
+		seen[p] = true
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			t, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || t.IsAlias() {
+				continue
+			}
+			if n, ok := t.Type().(*types.Named); ok && n.TypeParams().Len() > 0 {
+				continue
+			}
+			result = append(result, t)
+		}
+	}
+	for _, pkg := range m.sortedPackages() {
+		add(pkg.types)
+	}
+	if withImports {
+		for _, pkg := range m.sortedPackages() {
+			if pkg.types == nil {
+				continue
+			}
+			for _, imp := range pkg.types.Imports() {
+				add(imp)
+			}
+		}
+		result = append(result, types.Universe.Lookup("error").(*types.TypeName))
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return typePath(result[i]) < typePath(result[j])
+	})
+	return result
+}
+
+// typePath returns "pkgpath.Name" for sorting, with "" as the package of predeclared types.
+func typePath(t *types.TypeName) string {
+	if t.Pkg() == nil {
+		return "." + t.Name()
+	}
+	return t.Pkg().Path() + "." + t.Name()
+}
+
+// describeType renders a type name with its location, e.g. "*tools.Agent  main.go:52:6".
+func (m *goModule) describeType(t *types.TypeName, prefix string, qualify types.Qualifier) string {
+	name := prefix + types.TypeString(t.Type(), qualify)
+	if !t.Pos().IsValid() {
+		return name
+	}
+	pos := m.fset.Position(t.Pos())
+	return fmt.Sprintf("%s  %s:%d:%d", name, displayPath(pos.Filename), pos.Line, pos.Column)
+}
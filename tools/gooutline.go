@@ -0,0 +1,248 @@
+// Package tools provides the go_outline tool for the agent.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// GoOutlineDefinition is the tool that lists the declarations of a Go file or package.
+var GoOutlineDefinition = ToolDefinition{
+	Name:        "go_outline",
+	Description: "Outline a Go file or package directory without reading it: types with their fields and methods, functions with signatures, constants and variables, each with file:line range and the first line of its doc comment. Use this to discover what exists before reading code; then use go_read_symbol or readFileLines for the parts you need.",
+	InputSchema: GoOutlineInputSchema,
+	Function:    GoOutline,
+}
+
+// GoOutlineInput is the JSON shape for the go_outline tool.
+type GoOutlineInput struct {
+	Path         string `json:"path" jsonschema_description:"A .go file or a package directory, relative to the working directory; default is the current directory (.)."`
+	ExportedOnly bool   `json:"exportedOnly" jsonschema_description:"If true, list only exported declarations."`
+	IncludeTests bool   `json:"includeTests" jsonschema_description:"If true and path is a directory, include _test.go files."`
+	MaxChars     int    `json:"maxChars" jsonschema_description:"Optional cap on output size; 0 or omit means 20000."`
+}
+
+// GoOutlineInputSchema is the Anthropic tool input schema for go_outline.
+var GoOutlineInputSchema = GenerateSchema[GoOutlineInput]()
+
+// maxOutlineValueChars caps how much of a constant or variable's value the outline shows.
+const maxOutlineValueChars = 60
+
+// GoOutline implements the go_outline tool: parses the file or package and renders its declarations by kind.
+func GoOutline(input json.RawMessage) (string, error) {
+	var goOutlineInput GoOutlineInput
+	if err := json.Unmarshal(input, &goOutlineInput); err != nil {
+		return "", fmt.Errorf("go_outline input: %w", err)
+	}
+	path := goOutlineInput.Path
+	if path == "" {
+		path = "."
+	}
+	fset, files, err := parseGoSource(path, goOutlineInput.IncludeTests)
+	if err != nil {
+		return "", fmt.Errorf("go_outline: %w", err)
+	}
+	keep := func(name string) bool {
+		return !goOutlineInput.ExportedOnly || token.IsExported(name)
+	}
+
+	var typeNames []string
+	types := map[string][]string{}
+	methods := map[string][]string{}
+	var funcs, consts, vars []string
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if !keep(d.Name.Name) {
+					continue
+				}
+				line := outlineLine(funcSignature(fset, d), lineRange(fset, d), docSummary(d.Doc))
+				if recv := receiverTypeName(d); recv != "" {
+					methods[recv] = append(methods[recv], line)
+				} else {
+					funcs = append(funcs, line)
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						if !keep(s.Name.Name) {
+							continue
+						}
+						typeNames = append(typeNames, s.Name.Name)
+						types[s.Name.Name] = outlineType(fset, d, s, keep)
+					case *ast.ValueSpec:
+						lines := outlineValues(fset, d, s, keep)
+						if d.Tok == token.CONST {
+							consts = append(consts, lines...)
+						} else {
+							vars = append(vars, lines...)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "package %s (%d file(s))\n", files[0].Name.Name, len(files))
+	if len(typeNames) > 0 {
+		b.WriteString("types:\n")
+		for _, name := range typeNames {
+			for _, line := range types[name] {
+				b.WriteString("  " + line + "\n")
+			}
+			for _, line := range methods[name] {
+				b.WriteString("    " + line + "\n")
+			}
+			delete(methods, name)
+		}
+	}
+	// Methods on types declared outside the parsed files (or filtered out) are listed with functions.
+	orphans := make([]string, 0, len(methods))
+	for name := range methods {
+		orphans = append(orphans, name)
+	}
+	sort.Strings(orphans)
+	for _, name := range orphans {
+		funcs = append(funcs, methods[name]...)
+	}
+	for _, section := range []struct {
+		title string
+		lines []string
+	}{{"funcs", funcs}, {"consts", consts}, {"vars", vars}} {
+		if len(section.lines) == 0 {
+			continue
+		}
+		b.WriteString(section.title + ":\n")
+		for _, line := range section.lines {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	return capOutput(strings.TrimSuffix(b.String(), "\n"), goOutlineInput.MaxChars), nil
+}
+
+// outlineLine joins a declaration, its location and its doc summary into one outline line.
+func outlineLine(decl, location, doc string) string {
+	line := decl + "  " + location
+	if doc != "" {
+		line += "  // " + doc
+	}
+	return line
+}
+
+// outlineType renders a type spec: its header line, then one line per struct field or interface method.
+func outlineType(fset *token.FileSet, d *ast.GenDecl, s *ast.TypeSpec, keep func(string) bool) []string {
+	doc := s.Doc
+	if doc == nil && len(d.Specs) == 1 {
+		doc = d.Doc
+	}
+	var node ast.Node = s
+	if len(d.Specs) == 1 {
+		node = d
+	}
+	name := s.Name.Name
+	if s.TypeParams != nil {
+		var params []string
+		for _, p := range s.TypeParams.List {
+			params = append(params, joinIdents(p.Names)+" "+nodeString(fset, p.Type))
+		}
+		name += "[" + strings.Join(params, ", ") + "]"
+	}
+	var kind string
+	var members []*ast.Field
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		kind = "struct"
+		members = t.Fields.List
+	case *ast.InterfaceType:
+		kind = "interface"
+		members = t.Methods.List
+	default:
+		kind = nodeString(fset, s.Type)
+		if s.Assign.IsValid() {
+			kind = "= " + kind
+		}
+	}
+	lines := []string{outlineLine("type "+name+" "+kind, lineRange(fset, node), docSummary(doc))}
+	for _, field := range members {
+		var names []*ast.Ident
+		for _, n := range field.Names {
+			if keep(n.Name) {
+				names = append(names, n)
+			}
+		}
+		if len(field.Names) > 0 && len(names) == 0 {
+			continue
+		}
+		typ := nodeString(fset, field.Type)
+		var member string
+		switch {
+		case len(names) == 0:
+			member = typ // embedded field or interface
+		case kind == "interface":
+			member = names[0].Name + strings.TrimPrefix(typ, "func")
+		default:
+			member = joinIdents(names) + " " + typ
+		}
+		fieldDoc := docSummary(field.Doc)
+		if fieldDoc == "" {
+			fieldDoc = docSummary(field.Comment)
+		}
+		if fieldDoc != "" {
+			member += "  // " + fieldDoc
+		}
+		lines = append(lines, "  "+member)
+	}
+	return lines
+}
+
+// outlineValues renders each name of a const or var spec with its type and (shortened) value.
+func outlineValues(fset *token.FileSet, d *ast.GenDecl, s *ast.ValueSpec, keep func(string) bool) []string {
+	doc := s.Doc
+	if doc == nil {
+		doc = s.Comment
+	}
+	if doc == nil && len(d.Specs) == 1 {
+		doc = d.Doc
+	}
+	var node ast.Node = s
+	if len(d.Specs) == 1 {
+		node = d
+	}
+	var lines []string
+	for i, n := range s.Names {
+		if n.Name == "_" || !keep(n.Name) {
+			continue
+		}
+		decl := d.Tok.String() + " " + n.Name
+		if s.Type != nil {
+			decl += " " + nodeString(fset, s.Type)
+		}
+		if i < len(s.Values) {
+			decl += " = " + shortenValue(nodeString(fset, s.Values[i]))
+		}
+		lines = append(lines, outlineLine(decl, lineRange(fset, node), docSummary(doc)))
+	}
+	return lines
+}
+
+// shortenValue keeps the first line of a value expression, up to maxOutlineValueChars.
+func shortenValue(v string) string {
+	short := v
+	if i := strings.IndexByte(short, '\n'); i >= 0 {
+		short = short[:i]
+	}
+	if len(short) > maxOutlineValueChars {
+		short = short[:maxOutlineValueChars]
+	}
+	if short != v {
+		short += "…"
+	}
+	return short
+}
@@ -0,0 +1,217 @@
+// Package tools provides the go_read_symbol tool for the agent.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GoReadSymbolDefinition is the tool that returns the source of a single Go declaration.
+var GoReadSymbolDefinition = ToolDefinition{
+	Name:        "go_read_symbol",
+	Description: "Return the exact source of one Go declaration, with its doc comment and line numbers (\"12: text\"), without reading the rest of the file. name is a function, type, constant or variable (e.g. ToolDefinition), a method as Type.Method (e.g. Agent.runInterface), or a struct field or interface method as Type.Name. A bare name that is only a method returns every method with that name. Set includeMethods to also return a type's methods.",
+	InputSchema: GoReadSymbolInputSchema,
+	Function:    GoReadSymbol,
+}
+
+// GoReadSymbolInput is the JSON shape for the go_read_symbol tool.
+type GoReadSymbolInput struct {
+	Path           string `json:"path" jsonschema_description:"A .go file or a package directory, relative to the working directory; default is the current directory (.)."`
+	Name           string `json:"name" jsonschema_description:"The declaration to read: Name or Type.Method / Type.Field."`
+	IncludeMethods bool   `json:"includeMethods" jsonschema_description:"If true and name is a type, also return the source of its methods."`
+	IncludeTests   bool   `json:"includeTests" jsonschema_description:"If true and path is a directory, also search _test.go files."`
+}
+
+// GoReadSymbolInputSchema is the Anthropic tool input schema for go_read_symbol.
+var GoReadSymbolInputSchema = GenerateSchema[GoReadSymbolInput]()
+
+// symbolMatch is a declaration found by go_read_symbol: the node spanning its source and its doc comment.
+type symbolMatch struct {
+	node ast.Node
+	doc  *ast.CommentGroup
+}
+
+// GoReadSymbol implements the go_read_symbol tool: locates the named declaration in the AST and returns its source.
+func GoReadSymbol(input json.RawMessage) (string, error) {
+	var goReadSymbolInput GoReadSymbolInput
+	if err := json.Unmarshal(input, &goReadSymbolInput); err != nil {
+		return "", fmt.Errorf("go_read_symbol input: %w", err)
+	}
+	path := goReadSymbolInput.Path
+	if path == "" {
+		path = "."
+	}
+	name := strings.TrimSpace(goReadSymbolInput.Name)
+	if name == "" {
+		return "", fmt.Errorf("go_read_symbol: name is required")
+	}
+	fset, files, err := parseGoSource(path, goReadSymbolInput.IncludeTests)
+	if err != nil {
+		return "", fmt.Errorf("go_read_symbol: %w", err)
+	}
+	typeName, member, qualified := strings.Cut(name, ".")
+	if !qualified {
+		typeName, member = "", name
+	}
+
+	var matches []symbolMatch
+	var sameNamedMethods []symbolMatch // returned when a bare name matches only methods
+	var typeMethods []symbolMatch      // returned with includeMethods
+	var candidates []string
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				recv := receiverTypeName(d)
+				if recv != "" {
+					candidates = append(candidates, recv+"."+d.Name.Name)
+				} else {
+					candidates = append(candidates, d.Name.Name)
+				}
+				switch {
+				case d.Name.Name == member && recv == typeName:
+					matches = append(matches, symbolMatch{d, d.Doc})
+				case d.Name.Name == member && !qualified:
+					sameNamedMethods = append(sameNamedMethods, symbolMatch{d, d.Doc})
+				case !qualified && recv == member:
+					typeMethods = append(typeMethods, symbolMatch{d, d.Doc})
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					matches = append(matches, specMatches(d, spec, typeName, member, &candidates)...)
+				}
+			}
+		}
+	}
+	if len(matches) == 0 {
+		matches = sameNamedMethods
+	} else if goReadSymbolInput.IncludeMethods {
+		matches = append(matches, typeMethods...)
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("go_read_symbol: %s not found in %s%s", name, path, suggestSymbols(name, candidates))
+	}
+
+	blocks := make([]string, 0, len(matches))
+	for _, m := range matches {
+		block, err := symbolSource(fset, m)
+		if err != nil {
+			return "", err
+		}
+		blocks = append(blocks, block)
+	}
+	return strings.Join(blocks, "\n\n"), nil
+}
+
+// specMatches returns the parts of a const, var or type spec named by typeName/member, recording
+// every name it declares in candidates. For a spec inside a grouped declaration only that spec is
+// returned; a lone spec returns the whole declaration so the keyword and doc comment are included.
+func specMatches(d *ast.GenDecl, spec ast.Spec, typeName, member string, candidates *[]string) []symbolMatch {
+	whole := func(node ast.Node, doc *ast.CommentGroup) symbolMatch {
+		if len(d.Specs) == 1 {
+			return symbolMatch{d, d.Doc}
+		}
+		return symbolMatch{node, doc}
+	}
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		*candidates = append(*candidates, s.Name.Name)
+		if typeName == "" {
+			if s.Name.Name == member {
+				return []symbolMatch{whole(s, s.Doc)}
+			}
+			return nil
+		}
+		if s.Name.Name != typeName {
+			return nil
+		}
+		var fields []*ast.Field
+		switch t := s.Type.(type) {
+		case *ast.StructType:
+			fields = t.Fields.List
+		case *ast.InterfaceType:
+			fields = t.Methods.List
+		}
+		var found []symbolMatch
+		for _, field := range fields {
+			for _, n := range field.Names {
+				*candidates = append(*candidates, s.Name.Name+"."+n.Name)
+				if n.Name == member {
+					found = append(found, symbolMatch{field, field.Doc})
+				}
+			}
+		}
+		return found
+	case *ast.ValueSpec:
+		if typeName != "" {
+			return nil
+		}
+		for _, n := range s.Names {
+			*candidates = append(*candidates, n.Name)
+			if n.Name == member {
+				return []symbolMatch{whole(s, s.Doc)}
+			}
+		}
+	}
+	return nil
+}
+
+// symbolSource returns the numbered source lines of a match, starting at its doc comment, headed by its location.
+func symbolSource(fset *token.FileSet, m symbolMatch) (string, error) {
+	start := m.node.Pos()
+	if m.doc != nil && m.doc.Pos() < start {
+		start = m.doc.Pos()
+	}
+	startPos := fset.Position(start)
+	endPos := fset.Position(m.node.End())
+	content, err := os.ReadFile(startPos.Filename)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(content), "\n")
+	if endPos.Line > len(lines) {
+		endPos.Line = len(lines)
+	}
+	var b strings.Builder
+	if startPos.Line == endPos.Line {
+		fmt.Fprintf(&b, "%s:%d\n", startPos.Filename, startPos.Line)
+	} else {
+		fmt.Fprintf(&b, "%s:%d-%d\n", startPos.Filename, startPos.Line, endPos.Line)
+	}
+	for i := startPos.Line; i <= endPos.Line; i++ {
+		fmt.Fprintf(&b, "%d: %s\n", i, lines[i-1])
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// suggestSymbols lists up to ten declared names resembling name, as a hint appended to a not-found error.
+func suggestSymbols(name string, candidates []string) string {
+	lower := strings.ToLower(name)
+	_, member, _ := strings.Cut(lower, ".")
+	if member == "" {
+		member = lower
+	}
+	seen := map[string]bool{}
+	var similar []string
+	for _, c := range candidates {
+		lc := strings.ToLower(c)
+		if seen[c] || !(strings.Contains(lc, member) || strings.Contains(member, lc)) {
+			continue
+		}
+		seen[c] = true
+		similar = append(similar, c)
+	}
+	if len(similar) == 0 {
+		return ""
+	}
+	sort.Strings(similar)
+	if len(similar) > 10 {
+		similar = similar[:10]
+	}
+	return "; similar names: " + strings.Join(similar, ", ")
+}
@@ -0,0 +1,325 @@
+// Package tools provides the go_rename tool for the agent.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GoRenameDefinition is the tool that renames a Go identifier and every reference to it.
+var GoRenameDefinition = ToolDefinition{
+	Name:        "go_rename",
+	Description: "Rename the Go object named by the identifier at path:line:column (or name on that line) and every reference to it across the module, using type information so unrelated identifiers with the same name are left alone. Refuses when the new name is already declared, would shadow or capture another identifier, would break an interface implementation, would hide the object from other packages, when the name appears in files excluded by build constraints (other platforms or tags), or when the renamed module no longer type-checks. All files are written atomically; returns a unified diff of every touched file. Set check to preview the diff without writing.",
+	InputSchema: GoRenameInputSchema,
+	Function:    GoRename,
+}
+
+// GoRenameInput is the JSON shape for the go_rename tool.
+type GoRenameInput struct {
+	GoPositionInput
+	NewName string `json:"newName" jsonschema_description:"The new identifier."`
+	Check   bool   `json:"check" jsonschema_description:"If true, only validate the rename and return the diff; no file is changed."`
+}
+
+// GoRenameInputSchema is the Anthropic tool input schema for go_rename.
+var GoRenameInputSchema = GenerateSchema[GoRenameInput]()
+
+// maxRenameErrors caps how many new type errors a refused rename reports.
+const maxRenameErrors = 5
+
+// GoRename implements the go_rename tool: collects the object's identifiers, checks for conflicts,
+// verifies the result type-checks with the same references, then writes every file atomically.
+func GoRename(input json.RawMessage) (string, error) {
+	var goRenameInput GoRenameInput
+	if err := json.Unmarshal(input, &goRenameInput); err != nil {
+		return "", fmt.Errorf("go_rename input: %w", err)
+	}
+	newName := strings.TrimSpace(goRenameInput.NewName)
+	if !token.IsIdentifier(newName) || newName == "_" {
+		return "", fmt.Errorf("go_rename: %q is not a valid Go identifier", goRenameInput.NewName)
+	}
+	m, _, obj, err := resolveGoIdent("go_rename", goRenameInput.GoPositionInput)
+	if err != nil {
+		return "", err
+	}
+	oldName := obj.Name()
+	if oldName == newName {
+		return "", fmt.Errorf("go_rename: %s is already named %s", oldName, newName)
+	}
+	if obj.Pkg() == nil || !m.inModule(obj.Pkg().Path()) {
+		return "", fmt.Errorf("go_rename: %s is declared outside module %s and cannot be renamed", oldName, m.path)
+	}
+	if _, ok := obj.(*types.PkgName); ok {
+		return "", fmt.Errorf("go_rename: renaming imports is not supported; edit the import spec instead")
+	}
+	if err := m.loadAll(); err != nil {
+		return "", fmt.Errorf("go_rename: %w", err)
+	}
+	mentions, err := m.constrainedMentions(oldName)
+	if err != nil {
+		return "", fmt.Errorf("go_rename: %w", err)
+	}
+	if len(mentions) > 0 {
+		var places []string
+		for _, pos := range mentions[:min(len(mentions), maxRenameErrors)] {
+			places = append(places, fmt.Sprintf("%s:%d:%d", displayPath(pos.Filename), pos.Line, pos.Column))
+		}
+		if len(mentions) > maxRenameErrors {
+			places = append(places, fmt.Sprintf("and %d more", len(mentions)-maxRenameErrors))
+		}
+		return "", fmt.Errorf("go_rename: cannot rename %s: it is mentioned in files excluded by build constraints for %s/%s, which are not type-checked: %s; no files were changed", oldName, build.Default.GOOS, build.Default.GOARCH, strings.Join(places, ", "))
+	}
+	if err := m.renameConflicts(obj, newName); err != nil {
+		return "", fmt.Errorf("go_rename: cannot rename %s to %s: %w", oldName, newName, err)
+	}
+
+	// Collect every identifier denoting the object, grouped by file as byte offsets.
+	target := m.objectKey(obj)
+	offsets := map[string][]int{}
+	refCount := 0
+	for _, pkg := range m.sortedPackages() {
+		for _, objs := range []map[*ast.Ident]types.Object{pkg.info.Defs, pkg.info.Uses} {
+			for id, o := range objs {
+				if o == nil || m.objectKey(o) != target {
+					continue
+				}
+				pos := m.fset.Position(id.Pos())
+				offsets[pos.Filename] = append(offsets[pos.Filename], pos.Offset)
+				refCount++
+			}
+		}
+	}
+	var files []string
+	originals := map[string]string{}
+	overlay := map[string][]byte{}
+	for file, offs := range offsets {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("go_rename: %w", err)
+		}
+		sort.Ints(offs)
+		offsets[file] = offs
+		var b strings.Builder
+		last := 0
+		for _, off := range offs {
+			if string(content[off:off+len(oldName)]) != oldName {
+				return "", fmt.Errorf("go_rename: %s changed on disk since it was parsed; retry", displayPath(file))
+			}
+			b.Write(content[last:off])
+			b.WriteString(newName)
+			last = off + len(oldName)
+		}
+		b.Write(content[last:])
+		files = append(files, file)
+		originals[file] = string(content)
+		overlay[file] = []byte(b.String())
+	}
+	sort.Strings(files)
+
+	if err := m.verifyRename(obj, overlay, offsets, len(newName)-len(oldName), refCount); err != nil {
+		return "", fmt.Errorf("go_rename: cannot rename %s to %s: %w; no files were changed", oldName, newName, err)
+	}
+
+	var diffs strings.Builder
+	if goRenameInput.Check {
+		for _, file := range files {
+			diffs.WriteString(unifiedDiff(displayPath(file), originals[file], string(overlay[file]), true, false))
+		}
+		return fmt.Sprintf("Check passed: renaming %s to %s changes %d identifier(s) in %d file(s); nothing written.\n\n%s", oldName, newName, refCount, len(files), diffForResult(diffs.String())), nil
+	}
+	writes := make([]pendingWrite, 0, len(files))
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		path := displayPath(file)
+		paths = append(paths, path)
+		writes = append(writes, pendingWrite{path: path, content: overlay[file]})
+	}
+	if err := Checkpoints.Snapshot(paths...); err != nil {
+		return "", err
+	}
+	if err := writeFilesAtomically(writes); err != nil {
+		return "", fmt.Errorf("go_rename: %w", err)
+	}
+	for i, file := range files {
+		diffs.WriteString(reportDiff("go_rename", paths[i], originals[file], string(overlay[file]), true, false))
+	}
+	return fmt.Sprintf("Renamed %s to %s: %d identifier(s) in %d file(s)\n\n%s", oldName, newName, refCount, len(files), diffForResult(diffs.String())), nil
+}
+
+// renameConflicts reports why obj cannot be called newName: a declaration with that name in the
+// same scope or method set, lost visibility from other packages, or a broken interface implementation.
+// Shadowing and capture in nested scopes are caught afterwards by verifyRename.
+func (m *goModule) renameConflicts(obj types.Object, newName string) error {
+	if token.IsExported(obj.Name()) && !token.IsExported(newName) {
+		for _, pkg := range m.sortedPackages() {
+			if pkg.types == obj.Pkg() {
+				continue
+			}
+			for id, o := range pkg.info.Uses {
+				if o == obj {
+					return fmt.Errorf("it is used from package %s (%s), which could no longer see an unexported name", pkg.path, m.fset.Position(id.Pos()))
+				}
+			}
+		}
+	}
+	if scope := obj.Parent(); scope != nil {
+		if other := scope.Lookup(newName); other != nil {
+			return fmt.Errorf("%s is already declared in the same scope at %s", newName, m.fset.Position(other.Pos()))
+		}
+		return nil
+	}
+
+	// Fields and methods: the new name must not collide within the type's fields and method set.
+	v, isVar := obj.(*types.Var)
+	if isVar && v.Embedded() {
+		return fmt.Errorf("%s is an embedded field; rename its type instead", obj.Name())
+	}
+	for _, owner := range m.ownersOf(obj) {
+		for _, t := range []types.Type{owner, types.NewPointer(owner)} {
+			if other, _, _ := types.LookupFieldOrMethod(t, true, obj.Pkg(), newName); other != nil {
+				return fmt.Errorf("%s already has a field or method %s (%s)", types.TypeString(owner, nil), newName, m.fset.Position(other.Pos()))
+			}
+		}
+		if fn, ok := obj.(*types.Func); ok {
+			for _, iface := range m.declaredTypes(true) {
+				it, ok := iface.Type().Underlying().(*types.Interface)
+				if !ok || !it.IsMethodSet() || it.NumMethods() == 0 {
+					continue
+				}
+				if method, _, _ := types.LookupFieldOrMethod(it, false, fn.Pkg(), fn.Name()); method == nil {
+					continue
+				}
+				if types.Implements(owner, it) || types.Implements(types.NewPointer(owner), it) {
+					if _, isIface := owner.Underlying().(*types.Interface); !isIface {
+						return fmt.Errorf("%s would no longer implement %s", types.TypeString(owner, nil), types.TypeString(iface.Type(), nil))
+					}
+				}
+			}
+		}
+	}
+	if fn, ok := obj.(*types.Func); ok {
+		if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+			if it, ok := recv.Type().Underlying().(*types.Interface); ok {
+				for _, t := range m.declaredTypes(false) {
+					if _, isIface := t.Type().Underlying().(*types.Interface); isIface {
+						continue
+					}
+					if types.Implements(t.Type(), it) || types.Implements(types.NewPointer(t.Type()), it) {
+						return fmt.Errorf("%s implements this interface and would no longer do so; rename its method too", types.TypeString(t.Type(), nil))
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ownersOf returns the module's named types that declare obj as a field or method.
+func (m *goModule) ownersOf(obj types.Object) []types.Type {
+	if fn, ok := obj.(*types.Func); ok {
+		if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+			t := recv.Type()
+			if p, ok := t.(*types.Pointer); ok {
+				t = p.Elem()
+			}
+			return []types.Type{t}
+		}
+		return nil
+	}
+	var owners []types.Type
+	for _, t := range m.declaredTypes(false) {
+		st, ok := t.Type().Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			if st.Field(i) == obj {
+				owners = append(owners, t.Type())
+			}
+		}
+	}
+	return owners
+}
+
+// verifyRename type-checks the module with the renamed files and checks that no new type errors
+// appear and that the renamed object still has exactly refCount identifiers.
+func (m *goModule) verifyRename(obj types.Object, overlay map[string][]byte, offsets map[string][]int, delta, refCount int) error {
+	before := map[string]bool{}
+	for _, pkg := range m.sortedPackages() {
+		for _, err := range pkg.errors {
+			before[renameErrorKey(err)] = true
+		}
+	}
+	renamed := m.withOverlay(overlay)
+	if err := renamed.loadAll(); err != nil {
+		return err
+	}
+	var introduced []string
+	for _, pkg := range renamed.sortedPackages() {
+		for _, err := range pkg.errors {
+			if !before[renameErrorKey(err)] {
+				introduced = append(introduced, err.Error())
+			}
+		}
+	}
+	if len(introduced) > 0 {
+		if len(introduced) > maxRenameErrors {
+			introduced = append(introduced[:maxRenameErrors], fmt.Sprintf("… and %d more", len(introduced)-maxRenameErrors))
+		}
+		return fmt.Errorf("the result would not type-check:\n%s", strings.Join(introduced, "\n"))
+	}
+
+	// Locate the declaration in the renamed sources: its offset moves by delta per earlier rename in the file.
+	decl := m.fset.Position(obj.Pos())
+	shift := 0
+	for _, off := range offsets[decl.Filename] {
+		if off < decl.Offset {
+			shift += delta
+		}
+	}
+	pkg, f, err := renamed.packageForFile(decl.Filename)
+	if err != nil {
+		return err
+	}
+	tf := renamed.fset.File(f.Package)
+	declPos := tf.Pos(decl.Offset + shift)
+	var newObj types.Object
+	for id, o := range pkg.info.Defs {
+		if id.Pos() == declPos {
+			newObj = o
+		}
+	}
+	if newObj == nil {
+		return fmt.Errorf("the renamed declaration could not be found")
+	}
+	key := renamed.objectKey(newObj)
+	count := 0
+	for _, p := range renamed.sortedPackages() {
+		for _, objs := range []map[*ast.Ident]types.Object{p.info.Defs, p.info.Uses} {
+			for _, o := range objs {
+				if o != nil && renamed.objectKey(o) == key {
+					count++
+				}
+			}
+		}
+	}
+	if count != refCount {
+		return fmt.Errorf("the renamed object would have %d identifier(s) instead of %d (shadowing or capture)", count, refCount)
+	}
+	return nil
+}
+
+// renameErrorKey identifies a type error independently of positions shifted by the rename.
+func renameErrorKey(err error) string {
+	if terr, ok := err.(types.Error); ok {
+		return terr.Fset.Position(terr.Pos).Filename + ": " + terr.Msg
+	}
+	return err.Error()
+}
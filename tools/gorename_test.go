@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeModule creates a module named example.com/p in a temporary directory, makes it the working
+// directory and writes files into it.
+func writeModule(t *testing.T, files map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	files["go.mod"] = "module example.com/p\n\ngo 1.21\n"
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+}
+
+const renameSource = `package p
+
+func helper() int { return 1 }
+
+func use() int { return helper() }
+`
+
+func renameHelper(t *testing.T, check bool) (string, error) {
+	t.Helper()
+	input, err := json.Marshal(GoRenameInput{
+		GoPositionInput: GoPositionInput{Path: "p.go", Line: 3, Name: "helper"},
+		NewName:         "assist",
+		Check:           check,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return GoRename(input)
+}
+
+func TestGoRenameRefusesBuildConstrainedMentions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the constrained file is part of the build on windows")
+	}
+	writeModule(t, map[string]string{
+		"p.go": renameSource,
+		"p_windows.go": `//go:build windows
+
+package p
+
+func windowsUse() int { return helper() }
+`,
+	})
+	_, err := renameHelper(t, false)
+	if err == nil {
+		t.Fatal("rename succeeded; want a refusal because p_windows.go mentions helper")
+	}
+	if !strings.Contains(err.Error(), "p_windows.go:5:") {
+		t.Errorf("error %q does not point at p_windows.go:5", err)
+	}
+	content, err := os.ReadFile("p.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != renameSource {
+		t.Errorf("p.go was changed by a refused rename:\n%s", content)
+	}
+}
+
+func TestGoRenameRenamesReferences(t *testing.T) {
+	writeModule(t, map[string]string{"p.go": renameSource})
+	out, err := renameHelper(t, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "2 identifier(s) in 1 file(s)") {
+		t.Errorf("unexpected result:\n%s", out)
+	}
+	content, err := os.ReadFile("p.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.ReplaceAll(renameSource, "helper", "assist"); string(content) != want {
+		t.Errorf("p.go after rename:\n%s\nwant:\n%s", content, want)
+	}
+}
@@ -0,0 +1,374 @@
+// Package tools provides the go_test tool for the agent.
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GoTestDefinition is the tool that runs go test and returns a parsed summary.
+var GoTestDefinition = ToolDefinition{
+	Name:        "go_test",
+	Description: "Run go test -json for the given packages and return a compact summary: pass/fail/skip counts per package with durations, then only the failing tests' output with their file:line locations (paths relative to the working directory) and build errors. Supports run/skip patterns, race, count, short and timeout. Prefer this over runCommand for Go tests.",
+	InputSchema: GoTestInputSchema,
+	Function:    GoTest,
+}
+
+// GoTestInput is the JSON shape for the go_test tool.
+type GoTestInput struct {
+	Packages        []string `json:"packages" jsonschema_description:"Package patterns to test (e.g. ./..., ./tools); default ./...."`
+	Run             string   `json:"run" jsonschema_description:"Optional -run regexp selecting tests (e.g. ^TestParse$ or TestFoo/subcase)."`
+	Skip            string   `json:"skip" jsonschema_description:"Optional -skip regexp excluding tests."`
+	Race            bool     `json:"race" jsonschema_description:"If true, enable the race detector (-race)."`
+	Count           int      `json:"count" jsonschema_description:"Optional -count; 1 disables the test cache."`
+	Short           bool     `json:"short" jsonschema_description:"If true, pass -short."`
+	Timeout         string   `json:"timeout" jsonschema_description:"Optional -timeout as a Go duration (e.g. 30s, 5m); default 10m."`
+	WorkingDir      string   `json:"workingDir" jsonschema_description:"Optional directory to run in; default is the current directory."`
+	MaxFailureLines int      `json:"maxFailureLines" jsonschema_description:"Optional cap on output lines shown per failing test; 0 or omit means 60."`
+}
+
+// GoTestInputSchema is the Anthropic tool input schema for go_test.
+var GoTestInputSchema = GenerateSchema[GoTestInput]()
+
+const (
+	defaultGoTestTimeout      = 10 * time.Minute
+	defaultGoTestFailureLines = 60
+	maxGoTestSkipsListed      = 20
+)
+
+// testEvent is one line of go test -json output (see go doc test2json).
+type testEvent struct {
+	Action     string
+	Package    string
+	ImportPath string // build-output and build-fail events
+	Test       string
+	Elapsed    float64
+	Output     string
+}
+
+// testResult accumulates the events of one test, or of a package when test is "".
+type testResult struct {
+	pkg     string
+	test    string
+	action  string
+	elapsed float64
+	output  []string
+}
+
+// testLocation matches "file_test.go:12:" at the start of test output, as printed by t.Error and friends.
+var testLocation = regexp.MustCompile(`^\s*([\w.\-]+\.go):(\d+):`)
+
+// GoTest implements the go_test tool: runs go test -json, aggregates events, and formats failures.
+func GoTest(input json.RawMessage) (string, error) {
+	var goTestInput GoTestInput
+	if err := json.Unmarshal(input, &goTestInput); err != nil {
+		return "", fmt.Errorf("go_test input: %w", err)
+	}
+	timeout := defaultGoTestTimeout
+	if goTestInput.Timeout != "" {
+		d, err := time.ParseDuration(goTestInput.Timeout)
+		if err != nil || d <= 0 {
+			return "", fmt.Errorf("go_test: invalid timeout %q (use a Go duration such as 30s or 5m)", goTestInput.Timeout)
+		}
+		timeout = d
+	}
+	maxLines := goTestInput.MaxFailureLines
+	if maxLines <= 0 {
+		maxLines = defaultGoTestFailureLines
+	}
+	args := []string{"test", "-json", "-timeout", timeout.String()}
+	if goTestInput.Run != "" {
+		args = append(args, "-run", goTestInput.Run)
+	}
+	if goTestInput.Skip != "" {
+		args = append(args, "-skip", goTestInput.Skip)
+	}
+	if goTestInput.Race {
+		args = append(args, "-race")
+	}
+	if goTestInput.Count > 0 {
+		args = append(args, "-count", strconv.Itoa(goTestInput.Count))
+	}
+	if goTestInput.Short {
+		args = append(args, "-short")
+	}
+	packages := goTestInput.Packages
+	if len(packages) == 0 {
+		packages = []string{"./..."}
+	}
+	args = append(args, packages...)
+
+	// go test enforces -timeout itself and prints the hanging tests; the extra minute covers building.
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+time.Minute)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = goTestInput.WorkingDir
+	// Killing only go would leave a hung test binary holding the output pipes open.
+	startInProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd) }
+	cmd.WaitDelay = commandKillGracePeriod
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+	if errors.Is(runErr, exec.ErrWaitDelay) {
+		// go exited but left processes holding its output open: stop them and keep its status.
+		killProcessGroup(cmd)
+		runErr = nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("go_test: killed after %s without finishing", timeout+time.Minute)
+	}
+	if _, ok := runErr.(*exec.ExitError); runErr != nil && !ok {
+		return "", fmt.Errorf("go_test: %w", runErr)
+	}
+	return formatTestRun(&stdout, stderr.String(), goTestInput.WorkingDir, maxLines), nil
+}
+
+// formatTestRun aggregates go test -json output into a summary followed by failure details.
+func formatTestRun(stdout *bytes.Buffer, stderr, workDir string, maxLines int) string {
+	results := map[string]*testResult{}
+	var order []string
+	buildOutput := map[string][]string{}
+	var stray []string
+	get := func(pkg, test string) *testResult {
+		key := pkg + "\x00" + test
+		r, ok := results[key]
+		if !ok {
+			r = &testResult{pkg: pkg, test: test}
+			results[key] = r
+			order = append(order, key)
+		}
+		return r
+	}
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var ev testEvent
+		if len(line) == 0 || line[0] != '{' || json.Unmarshal(line, &ev) != nil {
+			stray = append(stray, string(line))
+			continue
+		}
+		switch ev.Action {
+		case "build-output":
+			buildOutput[ev.ImportPath] = append(buildOutput[ev.ImportPath], strings.TrimRight(ev.Output, "\n"))
+			continue
+		case "build-fail", "start":
+			continue
+		}
+		r := get(ev.Package, ev.Test)
+		switch ev.Action {
+		case "output":
+			r.output = append(r.output, strings.TrimRight(ev.Output, "\n"))
+		case "pass", "fail", "skip":
+			r.action = ev.Action
+			r.elapsed = ev.Elapsed
+		}
+	}
+
+	type pkgSummary struct {
+		pass, fail, skip int
+		result           *testResult
+	}
+	pkgs := map[string]*pkgSummary{}
+	var pkgOrder []string
+	var failed, skipped []*testResult
+	failedChildren := map[string]bool{}
+	for _, key := range order {
+		r := results[key]
+		s, ok := pkgs[r.pkg]
+		if !ok {
+			s = &pkgSummary{}
+			pkgs[r.pkg] = s
+			pkgOrder = append(pkgOrder, r.pkg)
+		}
+		if r.test == "" {
+			s.result = r
+			continue
+		}
+		switch r.action {
+		case "pass":
+			s.pass++
+		case "fail":
+			s.fail++
+			failed = append(failed, r)
+			if i := strings.LastIndex(r.test, "/"); i >= 0 {
+				failedChildren[r.pkg+"\x00"+r.test[:i]] = true
+			}
+		case "skip":
+			s.skip++
+			skipped = append(skipped, r)
+		}
+	}
+	// A parent test fails because its subtests did; count and show only the subtests.
+	for key := range failedChildren {
+		if r := results[key]; r != nil && r.action == "fail" {
+			pkgs[r.pkg].fail--
+		}
+	}
+	sort.Strings(pkgOrder)
+
+	var b strings.Builder
+	totalPass, totalFail, totalSkip, failedPkgs := 0, 0, 0, 0
+	var pkgLines []string
+	for _, pkg := range pkgOrder {
+		s := pkgs[pkg]
+		totalPass += s.pass
+		totalFail += s.fail
+		totalSkip += s.skip
+		status, elapsed := "?", ""
+		if s.result != nil {
+			switch s.result.action {
+			case "pass":
+				status = "ok"
+			case "fail":
+				status = "FAIL"
+				failedPkgs++
+			case "skip":
+				status = "?"
+			}
+			elapsed = fmt.Sprintf("%.2fs", s.result.elapsed)
+		}
+		counts := fmt.Sprintf("%d passed", s.pass)
+		if s.fail > 0 {
+			counts += fmt.Sprintf(", %d failed", s.fail)
+		}
+		if s.skip > 0 {
+			counts += fmt.Sprintf(", %d skipped", s.skip)
+		}
+		if s.pass+s.fail+s.skip == 0 {
+			counts = "no tests run"
+			if s.result != nil && s.result.action == "skip" {
+				counts = "no test files"
+			}
+		}
+		pkgLines = append(pkgLines, fmt.Sprintf("%-4s %s  %s  (%s)", status, pkg, elapsed, counts))
+	}
+	for pkg := range buildOutput {
+		if _, ok := pkgs[pkg]; !ok {
+			failedPkgs++
+		}
+	}
+	verdict := "PASS"
+	if totalFail > 0 || failedPkgs > 0 || len(buildOutput) > 0 {
+		verdict = "FAIL"
+	}
+	fmt.Fprintf(&b, "%s: %d passed, %d failed, %d skipped in %d package(s)\n", verdict, totalPass, totalFail, totalSkip, len(pkgOrder))
+	b.WriteString(strings.Join(pkgLines, "\n"))
+
+	if len(buildOutput) > 0 {
+		b.WriteString("\n\nBuild errors:")
+		var paths []string
+		for pkg := range buildOutput {
+			paths = append(paths, pkg)
+		}
+		sort.Strings(paths)
+		for _, pkg := range paths {
+			for _, line := range buildOutput[pkg] {
+				b.WriteString("\n" + line)
+			}
+		}
+	}
+
+	dirs := packageDirs(workDir)
+	for _, r := range failed {
+		if failedChildren[r.pkg+"\x00"+r.test] {
+			continue
+		}
+		fmt.Fprintf(&b, "\n\n--- FAIL: %s (%.2fs)  [%s]", r.test, r.elapsed, r.pkg)
+		writeTestOutput(&b, r.output, dirs[r.pkg], maxLines)
+	}
+	// A failed package without failing tests (panic in init or TestMain, timeout, setup error).
+	for _, pkg := range pkgOrder {
+		s := pkgs[pkg]
+		if _, built := buildOutput[pkg]; !built && s.result != nil && s.result.action == "fail" && s.fail == 0 {
+			fmt.Fprintf(&b, "\n\n--- FAIL: package %s", pkg)
+			writeTestOutput(&b, s.result.output, dirs[pkg], maxLines)
+		}
+	}
+	if len(skipped) > 0 && len(skipped) <= maxGoTestSkipsListed {
+		names := make([]string, len(skipped))
+		for i, r := range skipped {
+			names[i] = r.test
+		}
+		fmt.Fprintf(&b, "\n\nSkipped: %s", strings.Join(names, ", "))
+	}
+	extra := strings.TrimSpace(strings.Join(append(stray, stderr), "\n"))
+	if extra != "" {
+		b.WriteString("\n\nOther output:\n" + extra)
+	}
+	return capOutput(b.String(), 0)
+}
+
+// writeTestOutput appends a failing test's output without go test's === framing lines, rewriting
+// file:line locations to paths relative to the working directory and listing them first.
+func writeTestOutput(b *strings.Builder, output []string, dir string, maxLines int) {
+	var lines, locations []string
+	for _, line := range output {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- FAIL:") || trimmed == "FAIL" {
+			continue
+		}
+		if m := testLocation.FindStringSubmatchIndex(line); m != nil && dir != "" {
+			file := line[m[2]:m[3]]
+			loc := filepath.Join(dir, file) + ":" + line[m[4]:m[5]]
+			locations = append(locations, loc)
+			line = line[:m[2]] + filepath.Join(dir, file) + line[m[3]:]
+		}
+		lines = append(lines, line)
+	}
+	if len(locations) > 0 {
+		b.WriteString("\nat " + strings.Join(dedupe(locations), ", "))
+	}
+	if len(lines) > maxLines {
+		omitted := len(lines) - maxLines
+		lines = append(lines[:maxLines], fmt.Sprintf("    … %d more line(s); narrow with run or raise maxFailureLines", omitted))
+	}
+	for _, line := range lines {
+		b.WriteString("\n" + line)
+	}
+}
+
+// dedupe returns items without repeats, keeping the first occurrence order.
+func dedupe(items []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// packageDirs maps import paths of the module around workDir to directories relative to the
+// working directory, so test output locations can be made clickable. It returns nil outside a module.
+func packageDirs(workDir string) map[string]string {
+	dirs := map[string]string{}
+	cmd := exec.Command("go", "list", "-e", "-f", "{{.ImportPath}}\t{{.Dir}}", "./...")
+	cmd.Dir = workDir
+	out, err := cmd.Output()
+	if err != nil {
+		return dirs
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		pkg, dir, ok := strings.Cut(line, "\t")
+		if ok {
+			dirs[pkg] = displayPath(dir)
+		}
+	}
+	return dirs
+}
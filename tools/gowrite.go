@@ -0,0 +1,202 @@
+// Package tools provides the syntax check and formatting applied to Go files before they are written.
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxGoSyntaxErrors caps how many syntax errors a write reports.
+const maxGoSyntaxErrors = 10
+
+var (
+	goWriteMu            sync.Mutex
+	refuseGoSyntaxErrors bool
+)
+
+// SetRefuseGoSyntaxErrors controls writes of .go files that do not parse: when refuse is true the
+// write fails and nothing changes; otherwise (the default) the file is written unformatted and the
+// syntax errors are reported in the tool result.
+func SetRefuseGoSyntaxErrors(refuse bool) {
+	goWriteMu.Lock()
+	defer goWriteMu.Unlock()
+	refuseGoSyntaxErrors = refuse
+}
+
+// prepareGoWrite checks and formats content about to be written to path. For .go files that parse,
+// it returns the gofmt-formatted content with standard library imports grouped first, plus a note
+// when that changed anything. For .go files with syntax errors it returns content unchanged and a
+// note listing the errors, or an error if such writes are refused. Other files pass through.
+func prepareGoWrite(path, content string) (string, string, error) {
+	if filepath.Ext(path) != ".go" {
+		return content, "", nil
+	}
+	fset := token.NewFileSet()
+	if _, err := parser.ParseFile(fset, path, content, parser.ParseComments); err != nil {
+		errs := describeSyntaxErrors(err)
+		goWriteMu.Lock()
+		refuse := refuseGoSyntaxErrors
+		goWriteMu.Unlock()
+		if refuse {
+			return "", "", fmt.Errorf("%s has Go syntax errors; nothing was written:\n%s", path, errs)
+		}
+		return content, fmt.Sprintf("Warning: %s has Go syntax errors (written unformatted):\n%s", path, errs), nil
+	}
+	formatted, err := format.Source(groupStdImports([]byte(content), path))
+	if err != nil {
+		return content, fmt.Sprintf("Warning: gofmt failed on %s: %v", path, err), nil
+	}
+	if string(formatted) == content {
+		return content, "", nil
+	}
+	return string(formatted), fmt.Sprintf("Formatted %s with gofmt (the diff shows the formatted result).", path), nil
+}
+
+// noteLine returns note on a line of its own, for appending to a tool result, or "" when there is none.
+func noteLine(note string) string {
+	if note == "" {
+		return ""
+	}
+	return "\n" + note
+}
+
+// describeSyntaxErrors renders parser errors one per line as file:line:col: message.
+func describeSyntaxErrors(err error) string {
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		return err.Error()
+	}
+	var lines []string
+	for i, e := range list {
+		if i == maxGoSyntaxErrors {
+			lines = append(lines, fmt.Sprintf("… and %d more", len(list)-maxGoSyntaxErrors))
+			break
+		}
+		lines = append(lines, e.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// groupStdImports moves standard library imports in each parenthesized import block into a single
+// leading group, keeping the remaining groups (module and third-party imports) in their order.
+// Trailing // comments move with their import line and comment lines with the import below them;
+// blocks with /* */ comments or with comment lines not followed by an import are left alone.
+func groupStdImports(src []byte, path string) []byte {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return src
+	}
+	modPath := ""
+	if root := findGoModDir(filepath.Dir(path)); root != "" {
+		modPath, _ = readModulePath(filepath.Join(root, "go.mod"))
+	}
+	// Rewrite from the last block backwards so earlier offsets stay valid.
+	for i := len(f.Decls) - 1; i >= 0; i-- {
+		gd, ok := f.Decls[i].(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT || !gd.Lparen.IsValid() {
+			continue
+		}
+		start := fset.Position(gd.Lparen).Offset + 1
+		end := fset.Position(gd.Rparen).Offset
+		body, ok := regroupImportBlock(string(src[start:end]), modPath)
+		if !ok {
+			continue
+		}
+		src = append(append(append([]byte{}, src[:start]...), body...), src[end:]...)
+	}
+	return src
+}
+
+// importEntry is one import spec line together with the comment lines above it.
+type importEntry struct {
+	path  string
+	lines []string
+}
+
+// regroupImportBlock regroups the lines between an import block's parentheses, sorting each group
+// by import path. Comment lines stay attached to the import that follows them.
+func regroupImportBlock(body, modPath string) (string, bool) {
+	if strings.Contains(body, "/*") {
+		return "", false
+	}
+	var std []importEntry
+	var groups [][]importEntry
+	var current []importEntry
+	var pending []string
+	flush := func() {
+		if len(current) > 0 {
+			groups = append(groups, current)
+			current = nil
+		}
+	}
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			if len(pending) > 0 {
+				return "", false
+			}
+			flush()
+			continue
+		case strings.HasPrefix(trimmed, "//"):
+			pending = append(pending, line)
+			continue
+		}
+		q := strings.IndexByte(trimmed, '"')
+		if q < 0 {
+			return "", false
+		}
+		quoted, err := strconv.QuotedPrefix(trimmed[q:])
+		if err != nil {
+			return "", false
+		}
+		importPath, _ := strconv.Unquote(quoted)
+		entry := importEntry{path: importPath, lines: append(pending, line)}
+		pending = nil
+		if isStdImport(importPath, modPath) {
+			std = append(std, entry)
+		} else {
+			current = append(current, entry)
+		}
+	}
+	if len(pending) > 0 {
+		return "", false
+	}
+	flush()
+	if len(std) > 0 {
+		groups = append([][]importEntry{std}, groups...)
+	}
+	var b bytes.Buffer
+	b.WriteString("\n")
+	for i, g := range groups {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		sort.SliceStable(g, func(i, j int) bool { return g[i].path < g[j].path })
+		for _, e := range g {
+			b.WriteString(strings.Join(e.lines, "\n") + "\n")
+		}
+	}
+	return b.String(), true
+}
+
+// isStdImport reports whether an import path belongs to the standard library: its first element
+// has no dot and it is not inside the current module.
+func isStdImport(importPath, modPath string) bool {
+	if modPath != "" && (importPath == modPath || strings.HasPrefix(importPath, modPath+"/")) {
+		return false
+	}
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
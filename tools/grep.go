@@ -0,0 +1,95 @@
+// Package tools provides the line matcher and output formatting shared by grepInFile and grepInFiles.
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// grepOptions are the matching and output options common to the grep tools.
+type grepOptions struct {
+	pattern    string
+	regex      bool
+	ignoreCase bool
+	wholeWord  bool
+	before     int
+	after      int
+}
+
+// compileGrepPattern turns the options into a single RE2 expression: literal patterns are quoted,
+// whole-word matching wraps the pattern in \b, and ignoreCase adds (?i).
+func compileGrepPattern(opts grepOptions) (*regexp.Regexp, error) {
+	if opts.pattern == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+	expr := opts.pattern
+	if !opts.regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if opts.wholeWord {
+		expr = `\b(?:` + expr + `)\b`
+	}
+	if opts.ignoreCase {
+		expr = `(?i)` + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %w", opts.pattern, err)
+	}
+	return re, nil
+}
+
+// grepContextWindow resolves the before/after context from the specific and combined fields. The
+// specific fields are pointers so an explicit 0 can turn off one side of a combined context.
+func grepContextWindow(before, after *int, both int) (int, int) {
+	b, a := both, both
+	if before != nil {
+		b = *before
+	}
+	if after != nil {
+		a = *after
+	}
+	return max(b, 0), max(a, 0)
+}
+
+// grepLines returns the indices of lines matching re, stopping after limit matches (limit <= 0 means no limit).
+func grepLines(lines []string, re *regexp.Regexp, limit int) []int {
+	var hits []int
+	for i, line := range lines {
+		if limit > 0 && len(hits) >= limit {
+			break
+		}
+		if re.MatchString(line) {
+			hits = append(hits, i)
+		}
+	}
+	return hits
+}
+
+// formatGrepHits renders matches with line numbers, grep style: "12: text" for matches, "13- text"
+// for context lines, and "--" between non-adjacent groups. indent prefixes every line.
+func formatGrepHits(lines []string, hits []int, before, after int, indent string) string {
+	isHit := make(map[int]bool, len(hits))
+	for _, h := range hits {
+		isHit[h] = true
+	}
+	var b strings.Builder
+	last := -1
+	for _, h := range hits {
+		start := max(h-before, last+1)
+		if last >= 0 && start > last+1 {
+			b.WriteString(indent + "--\n")
+		}
+		end := min(h+after, len(lines)-1)
+		for i := start; i <= end; i++ {
+			sep := "-"
+			if isHit[i] {
+				sep = ":"
+			}
+			fmt.Fprintf(&b, "%s%d%s %s\n", indent, i+1, sep, strings.TrimRight(lines[i], "\r"))
+		}
+		last = max(last, end)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -4,23 +4,29 @@ package tools
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 )
 
 // GrepInFileDefinition is the tool that searches for a pattern inside a single file.
 var GrepInFileDefinition = ToolDefinition{
 	Name:        "grepInFile",
-	Description: "Search for a string pattern inside a single file; return matching lines with line numbers. Use when you need to find where something appears in a file.",
+	Description: "Search for a pattern inside a single file; return matching lines with line numbers (\"12: text\"), plus optional context lines (\"13- text\") so you rarely need a follow-up readFileLines. The pattern is a substring by default; set regex for RE2 regular expressions, ignoreCase and wholeWord as needed. outputMode \"count\" returns only the number of matching lines.",
 	InputSchema: GrepInFileInputSchema,
 	Function:    GrepInFile,
 }
 
 // GrepInFileInput is the JSON shape for the grepInFile tool.
 type GrepInFileInput struct {
-	Path       string `json:"path" jsonschema_description:"The relative path of the file to search."`
-	Pattern    string `json:"pattern" jsonschema_description:"The string to search for (substring match)."`
-	MaxMatches int    `json:"maxMatches" jsonschema_description:"Optional cap on number of matches returned; 0 or omit means 50."`
+	Path          string `json:"path" jsonschema_description:"The relative path of the file to search."`
+	Pattern       string `json:"pattern" jsonschema_description:"The string to search for (substring match, or RE2 regex if regex is true)."`
+	Regex         bool   `json:"regex" jsonschema_description:"If true, treat pattern as an RE2 regular expression."`
+	IgnoreCase    bool   `json:"ignoreCase" jsonschema_description:"If true, match case-insensitively."`
+	WholeWord     bool   `json:"wholeWord" jsonschema_description:"If true, only match the pattern at word boundaries."`
+	Context       int    `json:"context" jsonschema_description:"Optional number of context lines before and after each match."`
+	ContextBefore *int   `json:"contextBefore" jsonschema_description:"Optional number of context lines before each match (overrides context; 0 for none)."`
+	ContextAfter  *int   `json:"contextAfter" jsonschema_description:"Optional number of context lines after each match (overrides context; 0 for none)."`
+	OutputMode    string `json:"outputMode" jsonschema_description:"Optional: content (default) for matching lines, or count for the number of matching lines."`
+	MaxMatches    int    `json:"maxMatches" jsonschema_description:"Optional cap on number of matches returned; 0 or omit means 50."`
 }
 
 // GrepInFileInputSchema is the Anthropic tool input schema for grepInFile.
@@ -28,7 +34,7 @@ var GrepInFileInputSchema = GenerateSchema[GrepInFileInput]()
 
 const defaultGrepInFileMax = 50
 
-// GrepInFile implements the grepInFile tool: reads file, returns lines containing pattern with line numbers.
+// GrepInFile implements the grepInFile tool: reads file, returns lines matching pattern with line numbers and context.
 func GrepInFile(input json.RawMessage) (string, error) {
 	var grepInFileInput GrepInFileInput
 	if err := json.Unmarshal(input, &grepInFileInput); err != nil {
@@ -40,22 +46,42 @@ func GrepInFile(input json.RawMessage) (string, error) {
 	if maxMatches <= 0 {
 		maxMatches = defaultGrepInFileMax
 	}
-	content, err := os.ReadFile(path)
+	re, err := compileGrepPattern(grepOptions{
+		pattern:    pattern,
+		regex:      grepInFileInput.Regex,
+		ignoreCase: grepInFileInput.IgnoreCase,
+		wholeWord:  grepInFileInput.WholeWord,
+	})
+	if err != nil {
+		return "", fmt.Errorf("grepInFile: %w", err)
+	}
+	content, info, err := readText(path)
 	if err != nil {
 		return "", err
 	}
-	lines := strings.Split(string(content), "\n")
-	var matches []string
-	for i, line := range lines {
-		if len(matches) >= maxMatches {
-			break
-		}
-		if strings.Contains(line, pattern) {
-			matches = append(matches, fmt.Sprintf("%d: %s", i+1, line))
-		}
-	}
-	if len(matches) == 0 {
+	if info.binary {
+		return fmt.Sprintf("%s is a binary file (%s); not searched", path, info), nil
+	}
+	lines := strings.Split(content, "\n")
+	switch grepInFileInput.OutputMode {
+	case "", "content":
+	case "count":
+		return fmt.Sprintf("%d matching line(s) for %q in %s", len(grepLines(lines, re, 0)), pattern, path), nil
+	default:
+		return "", fmt.Errorf("grepInFile: outputMode must be content or count")
+	}
+	hits := grepLines(lines, re, maxMatches+1)
+	if len(hits) == 0 {
 		return fmt.Sprintf("No matches for %q in %s", pattern, path), nil
 	}
-	return strings.Join(matches, "\n"), nil
+	truncated := len(hits) > maxMatches
+	if truncated {
+		hits = hits[:maxMatches]
+	}
+	before, after := grepContextWindow(grepInFileInput.ContextBefore, grepInFileInput.ContextAfter, grepInFileInput.Context)
+	result := formatGrepHits(lines, hits, before, after, "")
+	if truncated {
+		result += fmt.Sprintf("\n[Stopped after %d matches; raise maxMatches or narrow the pattern.]", maxMatches)
+	}
+	return result, nil
 }
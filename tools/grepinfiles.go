@@ -12,17 +12,25 @@ import (
 // GrepInFilesDefinition is the tool that searches for a pattern in files under a directory.
 var GrepInFilesDefinition = ToolDefinition{
 	Name:        "grepInFiles",
-	Description: "Search for a string pattern in files under a directory; return file path and matching lines. Optionally filter by glob (e.g. *.go).",
+	Description: "Search for a pattern in files under a directory; results are grouped by file with line numbers (\"12: text\") and optional context lines (\"13- text\"). The pattern is a substring by default; set regex for RE2 regular expressions, ignoreCase and wholeWord as needed. Optionally filter by glob (e.g. *.go). Binary files and files over 10 MB are skipped. Files ignored by .gitignore or .agentignore and directories like .git and node_modules are skipped unless includeIgnored is set. outputMode \"count\" returns per-file match counts and \"files\" returns only the matching file paths.",
 	InputSchema: GrepInFilesInputSchema,
 	Function:    GrepInFiles,
 }
 
 // GrepInFilesInput is the JSON shape for the grepInFiles tool.
 type GrepInFilesInput struct {
-	RootPath   string `json:"rootPath" jsonschema_description:"Directory to search in; default is current directory (.)."`
-	Pattern    string `json:"pattern" jsonschema_description:"The string to search for (substring match)."`
-	Glob       string `json:"glob" jsonschema_description:"Optional glob to filter files (e.g. *.go); empty means all files."`
-	MaxResults int    `json:"maxResults" jsonschema_description:"Optional cap on total match count; 0 or omit means 100."`
+	RootPath       string `json:"rootPath" jsonschema_description:"Directory to search in; default is current directory (.)."`
+	Pattern        string `json:"pattern" jsonschema_description:"The string to search for (substring match, or RE2 regex if regex is true)."`
+	Glob           string `json:"glob" jsonschema_description:"Optional glob to filter files (e.g. *.go); empty means all files."`
+	Regex          bool   `json:"regex" jsonschema_description:"If true, treat pattern as an RE2 regular expression."`
+	IgnoreCase     bool   `json:"ignoreCase" jsonschema_description:"If true, match case-insensitively."`
+	WholeWord      bool   `json:"wholeWord" jsonschema_description:"If true, only match the pattern at word boundaries."`
+	Context        int    `json:"context" jsonschema_description:"Optional number of context lines before and after each match."`
+	ContextBefore  *int   `json:"contextBefore" jsonschema_description:"Optional number of context lines before each match (overrides context; 0 for none)."`
+	ContextAfter   *int   `json:"contextAfter" jsonschema_description:"Optional number of context lines after each match (overrides context; 0 for none)."`
+	OutputMode     string `json:"outputMode" jsonschema_description:"Optional: content (default) for matching lines grouped by file, count for per-file match counts, files for matching file paths only."`
+	MaxResults     int    `json:"maxResults" jsonschema_description:"Optional cap on total match count, or on files listed in count and files modes; 0 or omit means 100."`
+	IncludeIgnored bool   `json:"includeIgnored" jsonschema_description:"If true, also search files ignored by .gitignore/.agentignore and junk directories such as node_modules (.git is always skipped)."`
 }
 
 // GrepInFilesInputSchema is the Anthropic tool input schema for grepInFiles.
@@ -30,7 +38,10 @@ var GrepInFilesInputSchema = GenerateSchema[GrepInFilesInput]()
 
 const defaultGrepInFilesMax = 100
 
-// GrepInFiles implements the grepInFiles tool: walks directory, searches each file, returns path:lineNum: line.
+// grepMaxFileBytes is the size above which grepInFiles skips a file rather than reading it.
+const grepMaxFileBytes = 10 * 1024 * 1024
+
+// GrepInFiles implements the grepInFiles tool: walks directory, searches each file, returns matches grouped by file.
 func GrepInFiles(input json.RawMessage) (string, error) {
 	var grepInFilesInput GrepInFilesInput
 	if err := json.Unmarshal(input, &grepInFilesInput); err != nil {
@@ -47,6 +58,23 @@ func GrepInFiles(input json.RawMessage) (string, error) {
 	if maxResults <= 0 {
 		maxResults = defaultGrepInFilesMax
 	}
+	mode := grepInFilesInput.OutputMode
+	if mode == "" {
+		mode = "content"
+	}
+	if mode != "content" && mode != "count" && mode != "files" {
+		return "", fmt.Errorf("grepInFiles: outputMode must be content, count or files")
+	}
+	re, err := compileGrepPattern(grepOptions{
+		pattern:    pattern,
+		regex:      grepInFilesInput.Regex,
+		ignoreCase: grepInFilesInput.IgnoreCase,
+		wholeWord:  grepInFilesInput.WholeWord,
+	})
+	if err != nil {
+		return "", fmt.Errorf("grepInFiles: %w", err)
+	}
+	before, after := grepContextWindow(grepInFilesInput.ContextBefore, grepInFilesInput.ContextAfter, grepInFilesInput.Context)
 	info, err := os.Stat(rootPath)
 	if err != nil {
 		return "", err
@@ -55,10 +83,9 @@ func GrepInFiles(input json.RawMessage) (string, error) {
 		return "", fmt.Errorf("grepInFiles: rootPath must be a directory: %s", rootPath)
 	}
 	var results []string
-	err = filepath.WalkDir(rootPath, func(path string, d os.DirEntry, walkErr error) error {
-		if walkErr != nil {
-			return walkErr
-		}
+	total := 0
+	truncated := false
+	err = walkTree(rootPath, grepInFilesInput.IncludeIgnored, func(path string, d os.DirEntry) error {
 		if d.IsDir() {
 			return nil
 		}
@@ -68,17 +95,50 @@ func GrepInFiles(input json.RawMessage) (string, error) {
 				return nil
 			}
 		}
-		content, err := os.ReadFile(path)
-		if err != nil {
+		if info, err := d.Info(); err != nil || info.Size() > grepMaxFileBytes {
 			return nil
 		}
-		lines := strings.Split(string(content), "\n")
-		for i, line := range lines {
-			if len(results) >= maxResults {
-				return filepath.SkipAll
+		content, info, err := readText(path)
+		if err != nil || info.binary {
+			return nil
+		}
+		lines := strings.Split(content, "\n")
+		switch mode {
+		case "files":
+			// Match line by line like the other modes, stopping at the first hit.
+			if len(grepLines(lines, re, 1)) > 0 {
+				if total >= maxResults {
+					truncated = true
+					return filepath.SkipAll
+				}
+				total++
+				results = append(results, path)
+			}
+		case "count":
+			// The cap applies to files listed, so every count shown is complete.
+			if n := len(grepLines(lines, re, 0)); n > 0 {
+				if len(results) >= maxResults {
+					truncated = true
+					return filepath.SkipAll
+				}
+				total += n
+				results = append(results, fmt.Sprintf("%s: %d", path, n))
+			}
+		default:
+			hits := grepLines(lines, re, maxResults-total+1)
+			if len(hits) == 0 {
+				return nil
+			}
+			if total+len(hits) > maxResults {
+				hits = hits[:maxResults-total]
+				truncated = true
 			}
-			if strings.Contains(line, pattern) {
-				results = append(results, fmt.Sprintf("%s:%d: %s", path, i+1, line))
+			total += len(hits)
+			if len(hits) > 0 {
+				results = append(results, path+"\n"+formatGrepHits(lines, hits, before, after, "  "))
+			}
+			if truncated {
+				return filepath.SkipAll
 			}
 		}
 		return nil
@@ -89,5 +149,12 @@ func GrepInFiles(input json.RawMessage) (string, error) {
 	if len(results) == 0 {
 		return fmt.Sprintf("No matches for %q under %s", pattern, rootPath), nil
 	}
-	return strings.Join(results, "\n"), nil
+	result := strings.Join(results, "\n")
+	if mode == "count" {
+		result += fmt.Sprintf("\ntotal: %d matching line(s) in %d file(s)", total, len(results))
+	}
+	if truncated {
+		result += fmt.Sprintf("\n[Stopped after %d results; raise maxResults or narrow the search.]", maxResults)
+	}
+	return result, nil
 }
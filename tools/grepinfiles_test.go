@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGrepInFilesCountModeHonoursMaxResults(t *testing.T) {
+	t.Chdir(t.TempDir())
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(name, []byte("x\nx\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := GrepInFiles([]byte(`{"pattern":"x","outputMode":"count","maxResults":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "total: 4 matching line(s) in 2 file(s)") || !strings.Contains(out, "[Stopped after 2 results") {
+		t.Errorf("count mode ignored maxResults:\n%s", out)
+	}
+}
+
+func TestGrepInFilesExplicitZeroContext(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("a.txt", []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := GrepInFiles([]byte(`{"pattern":"two","context":1,"contextBefore":0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a.txt\n  2: two\n  3- three"; out != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
@@ -0,0 +1,387 @@
+// Package tools provides the html_query tool for the agent.
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// HTMLQueryDefinition is the tool that extracts elements matching CSS selectors from a page or file.
+var HTMLQueryDefinition = ToolDefinition{
+	Name:        "html_query",
+	Description: "Extract elements matching CSS selectors from a web page (url) or a local HTML or XML file (path), e.g. one table of a docs page, rows of a coverage report or test cases of a JUnit XML file. For each selector returns the matches' text (default), Markdown, outer HTML or attributes, up to maxMatches per selector and maxChars per match. XML element and attribute names are matched in lower case without namespace prefixes.",
+	InputSchema: HTMLQueryInputSchema,
+	Function:    HTMLQuery,
+}
+
+// HTMLQueryInput is the JSON shape for the html_query tool.
+type HTMLQueryInput struct {
+	URL        string   `json:"url" jsonschema_description:"http or https URL to fetch; give either url or path."`
+	Path       string   `json:"path" jsonschema_description:"Relative path of a local HTML or XML file; give either url or path."`
+	Selectors  []string `json:"selectors" jsonschema_description:"CSS selectors, each queried separately, e.g. [\"table.files tr\", \"a[href$='.pdf']\"]."`
+	Output     string   `json:"output" jsonschema_description:"What to return for each match: text (default; whitespace-normalized, one line per block and tab-separated table cells), markdown (HTML only), html (outer HTML or XML) or attributes (only attributes)."`
+	Attributes []string `json:"attributes" jsonschema_description:"Attribute names to include with each match, e.g. [\"href\"]; [\"*\"] for all. With output attributes and none listed, all attributes are shown."`
+	Format     string   `json:"format" jsonschema_description:"auto (default; from the file extension, Content-Type or content), html or xml."`
+	MaxMatches int      `json:"maxMatches" jsonschema_description:"Maximum matches shown per selector (the total is still counted); default 20, maximum 500."`
+	MaxChars   int      `json:"maxChars" jsonschema_description:"Maximum characters shown per match; default 2000."`
+}
+
+// HTMLQueryInputSchema is the Anthropic tool input schema for html_query.
+var HTMLQueryInputSchema = GenerateSchema[HTMLQueryInput]()
+
+const (
+	defaultHTMLQueryMatches = 20
+	maxHTMLQueryMatches     = 500
+	defaultHTMLQueryChars   = 2000
+	maxHTMLQueryFileBytes   = 20 * 1024 * 1024
+)
+
+// xmlExtensions are file extensions parsed as XML when format is auto.
+var xmlExtensions = wordSet(".xml", ".svg", ".rss", ".atom", ".xsd", ".xsl", ".xslt", ".plist", ".pom", ".kml", ".gpx", ".xlf", ".csproj", ".resx")
+
+// HTMLQuery implements the html_query tool.
+func HTMLQuery(input json.RawMessage) (string, error) {
+	var in HTMLQueryInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("html_query input: %w", err)
+	}
+	output := strings.TrimSpace(in.Output)
+	switch output {
+	case "":
+		output = "text"
+	case "text", "markdown", "html", "attributes":
+	default:
+		return "", fmt.Errorf("html_query: output must be text, markdown, html or attributes, not %q", in.Output)
+	}
+	format := strings.TrimSpace(in.Format)
+	switch format {
+	case "", "auto":
+		format = ""
+	case "html", "xml":
+	default:
+		return "", fmt.Errorf("html_query: format must be auto, html or xml, not %q", in.Format)
+	}
+	var selectors []string
+	var matchers []cascadia.Selector
+	for _, sel := range in.Selectors {
+		if sel = strings.TrimSpace(sel); sel == "" {
+			continue
+		}
+		m, err := cascadia.Compile(sel)
+		if err != nil {
+			return "", fmt.Errorf("html_query: selector %q: %v", sel, err)
+		}
+		selectors, matchers = append(selectors, sel), append(matchers, m)
+	}
+	if len(selectors) == 0 {
+		return "", fmt.Errorf("html_query: selectors is required")
+	}
+	maxMatches := defaultHTMLQueryMatches
+	if in.MaxMatches > 0 {
+		maxMatches = min(in.MaxMatches, maxHTMLQueryMatches)
+	}
+	maxChars := defaultHTMLQueryChars
+	if in.MaxChars > 0 {
+		maxChars = in.MaxChars
+	}
+
+	var (
+		data        []byte
+		source      string
+		contentType string
+		base        *url.URL
+		notes       []string
+	)
+	switch {
+	case in.URL != "" && in.Path != "":
+		return "", fmt.Errorf("html_query: give either url or path, not both")
+	case in.URL != "":
+		resp, body, err := fetchURL("html_query", in.URL)
+		if err != nil {
+			return "", err
+		}
+		data, base, contentType = body, resp.Request.URL, resp.Header.Get("Content-Type")
+		source = base.String()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			notes = append(notes, fmt.Sprintf("HTTP status: %s", resp.Status))
+		}
+	case in.Path != "":
+		info, err := classifyFile(in.Path)
+		if err != nil {
+			return "", fmt.Errorf("html_query: %w", err)
+		}
+		if info.size > maxHTMLQueryFileBytes {
+			return "", fmt.Errorf("html_query: %s is %s; files over %s are not parsed", in.Path, formatSize(info.size), formatSize(maxHTMLQueryFileBytes))
+		}
+		text, _, err := readText(in.Path)
+		if err != nil {
+			return "", fmt.Errorf("html_query: %w", err)
+		}
+		data, source = []byte(text), in.Path
+		if format == "" && xmlExtensions[strings.ToLower(filepath.Ext(in.Path))] {
+			format = "xml"
+		}
+	default:
+		return "", fmt.Errorf("html_query: url or path is required")
+	}
+	if format == "" {
+		format = "html"
+		if looksLikeXML(contentType, data) {
+			format = "xml"
+		}
+	}
+	if format == "xml" && output == "markdown" {
+		return "", fmt.Errorf("html_query: markdown output is only available for HTML; use text or html")
+	}
+
+	var root *html.Node
+	if format == "xml" {
+		var err error
+		if root, err = parseXMLNodes(data); err != nil {
+			return "", fmt.Errorf("html_query: parse XML %s: %w", source, err)
+		}
+	} else {
+		var err error
+		if root, err = html.Parse(bytes.NewReader(data)); err != nil {
+			return "", fmt.Errorf("html_query: parse HTML %s: %w", source, err)
+		}
+	}
+	doc := goquery.NewDocumentFromNode(root)
+	c := markdownConverter{base: base}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s)\n", source, format)
+	for _, note := range notes {
+		b.WriteString(note + "\n")
+	}
+	truncated := false
+	for i, sel := range selectors {
+		matches := doc.FindMatcher(matchers[i])
+		n := matches.Length()
+		switch {
+		case n == 0:
+			fmt.Fprintf(&b, "\nselector %q: no matches\n", sel)
+			continue
+		case n == 1:
+			fmt.Fprintf(&b, "\nselector %q: 1 match\n", sel)
+		case n > maxMatches:
+			fmt.Fprintf(&b, "\nselector %q: %d matches, showing %d\n", sel, n, maxMatches)
+		default:
+			fmt.Fprintf(&b, "\nselector %q: %d matches\n", sel, n)
+		}
+		for j, node := range matches.Nodes[:min(n, maxMatches)] {
+			if b.Len() > fetchHTMLMaxReturnChars {
+				truncated = true
+				break
+			}
+			fmt.Fprintf(&b, "[%d]", j+1)
+			attrs := formatAttributes(node, in.Attributes, output == "attributes")
+			if attrs != "" {
+				b.WriteString(" " + attrs)
+			}
+			var content string
+			switch output {
+			case "text":
+				content = blockText(node)
+			case "markdown":
+				if inlineElements[node.Data] {
+					content = trimLines(c.inline(node))
+				} else {
+					content = strings.TrimSpace(c.block(node))
+				}
+			case "html":
+				if format == "xml" {
+					content = renderXML(node)
+				} else {
+					var buf bytes.Buffer
+					html.Render(&buf, node)
+					content = buf.String()
+				}
+			}
+			if len(content) > maxChars {
+				cut := maxChars
+				for cut > 0 && !utf8.RuneStart(content[cut]) {
+					cut--
+				}
+				content = content[:cut] + fmt.Sprintf(" … [%d more chars]", len(content)-cut)
+			}
+			switch {
+			case content == "":
+				b.WriteString("\n")
+			case strings.Contains(content, "\n") || attrs != "":
+				b.WriteString("\n" + content + "\n")
+			default:
+				b.WriteString(" " + content + "\n")
+			}
+		}
+	}
+	if truncated {
+		fmt.Fprintf(&b, "\n[Output truncated at %d characters; use fewer selectors, maxMatches or maxChars.]\n", fetchHTMLMaxReturnChars)
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// looksLikeXML reports whether a document is XML rather than HTML, going by its Content-Type or,
+// when that says nothing, an XML declaration without an html element.
+func looksLikeXML(contentType string, data []byte) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.Contains(contentType, "html") {
+		return false
+	}
+	if strings.Contains(contentType, "xml") {
+		return true
+	}
+	head := bytes.ToLower(data[:min(len(data), 1024)])
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	return bytes.HasPrefix(head, []byte("<?xml")) && !bytes.Contains(head, []byte("<html"))
+}
+
+// parseXMLNodes parses an XML document into an html.Node tree that goquery can query. Element and
+// attribute names are lowercased local names, since selectors are matched in lower case.
+func parseXMLNodes(data []byte) (*html.Node, error) {
+	root := &html.Node{Type: html.DocumentNode}
+	cur := root
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	dec.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) {
+		return r, nil // readText has already decoded UTF-16; treat other charsets as UTF-8
+	}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &html.Node{Type: html.ElementNode, Data: strings.ToLower(t.Name.Local)}
+			for _, a := range t.Attr {
+				n.Attr = append(n.Attr, html.Attribute{Key: strings.ToLower(a.Name.Local), Val: a.Value})
+			}
+			cur.AppendChild(n)
+			cur = n
+		case xml.EndElement:
+			if cur.Parent != nil {
+				cur = cur.Parent
+			}
+		case xml.CharData:
+			cur.AppendChild(&html.Node{Type: html.TextNode, Data: string(t)})
+		}
+	}
+	return root, nil
+}
+
+// renderXML writes n back out as XML.
+func renderXML(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			xml.EscapeText(&b, []byte(n.Data))
+		case html.ElementNode:
+			b.WriteString("<" + n.Data)
+			for _, a := range n.Attr {
+				b.WriteString(" " + a.Key + `="`)
+				xml.EscapeText(&b, []byte(a.Val))
+				b.WriteString(`"`)
+			}
+			if n.FirstChild == nil {
+				b.WriteString("/>")
+				return
+			}
+			b.WriteString(">")
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				walk(child)
+			}
+			b.WriteString("</" + n.Data + ">")
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// blockText returns the text under n with one line per block element and table cells separated
+// by tabs, whitespace within lines collapsed.
+func blockText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(collapseSpaceKeepEdges(n.Data))
+			return
+		case n.Type != html.ElementNode:
+		case n.Data == "br":
+			b.WriteString("\n")
+			return
+		case n.Data == "td" || n.Data == "th":
+			b.WriteString("\t")
+		case !inlineElements[n.Data]:
+			b.WriteString("\n")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if n.Type == html.ElementNode && !inlineElements[n.Data] && n.Data != "td" && n.Data != "th" {
+			b.WriteString("\n")
+		}
+	}
+	walk(n)
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		cells := strings.Split(line, "\t")
+		for i, cell := range cells {
+			cells[i] = collapseSpace(cell)
+		}
+		if line = strings.Trim(strings.Join(cells, "\t"), "\t"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatAttributes renders the named attributes of n as name="value" pairs in the order given,
+// or all of them for "*" or when all is set and none are named.
+func formatAttributes(n *html.Node, names []string, all bool) string {
+	all = all && len(names) == 0 || slices.Contains(names, "*")
+	var pairs []string
+	if all {
+		for _, a := range n.Attr {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", a.Key, a.Val))
+		}
+		return strings.Join(pairs, " ")
+	}
+	for _, name := range names {
+		if v, ok := attrValue(n, name); ok {
+			pairs = append(pairs, fmt.Sprintf("%s=%q", name, v))
+		}
+	}
+	return strings.Join(pairs, " ")
+}
+
+// attrValue returns the value of n's attribute name and whether it is present.
+func attrValue(n *html.Node, name string) (string, bool) {
+	name = strings.ToLower(name)
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
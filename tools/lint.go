@@ -0,0 +1,270 @@
+// Package tools provides the lint tool for the agent.
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LintDefinition is the tool that runs the project's configured linter and returns structured issues.
+var LintDefinition = ToolDefinition{
+	Name:        "lint",
+	Description: "Run the project's linter and return structured issues (file, line, column, linter, message) grouped by file with source context. Uses the module's go-lint script if present, else golangci-lint when a .golangci config exists and it is installed, else go vet. Set changedOnly to report only files changed in this session.",
+	InputSchema: LintInputSchema,
+	Function:    Lint,
+}
+
+// LintInput is the JSON shape for the lint tool.
+type LintInput struct {
+	Packages     []string `json:"packages" jsonschema_description:"Package patterns to lint (e.g. ./..., ./tools); default ./...."`
+	ChangedOnly  bool     `json:"changedOnly" jsonschema_description:"If true, only report issues in files changed by the agent in this session."`
+	ContextLines int      `json:"contextLines" jsonschema_description:"Source lines shown before and after each issue; default 2, -1 for none."`
+	MaxIssues    int      `json:"maxIssues" jsonschema_description:"Maximum issues to report; default 100."`
+	WorkingDir   string   `json:"workingDir" jsonschema_description:"Optional directory to run in; default is the current directory."`
+}
+
+// LintInputSchema is the Anthropic tool input schema for lint.
+var LintInputSchema = GenerateSchema[LintInput]()
+
+const (
+	defaultLintMaxIssues = 100
+	lintTimeout          = 10 * time.Minute
+)
+
+// golangciConfigs are the configuration file names golangci-lint looks for.
+var golangciConfigs = []string{".golangci.yml", ".golangci.yaml", ".golangci.toml", ".golangci.json"}
+
+// golangciV2Config matches the version key of a golangci-lint v2 configuration.
+var golangciV2Config = regexp.MustCompile(`(?m)^\s*"?version"?\s*[:=]\s*["']?2`)
+
+// linterCommand is a discovered lint command: the program and arguments before the package
+// patterns, and the directory golangci-lint reports paths relative to.
+type linterCommand struct {
+	name    string // for the result header, e.g. "golangci-lint via ./go-lint"
+	program string
+	args    []string
+	baseDir string
+}
+
+// golangciIssue is one entry of golangci-lint's JSON output.
+type golangciIssue struct {
+	FromLinter string
+	Text       string
+	Severity   string
+	Pos        struct {
+		Filename string
+		Line     int
+		Column   int
+	}
+}
+
+// Lint implements the lint tool: discovers the linter, runs it with JSON output and reports its issues.
+func Lint(input json.RawMessage) (string, error) {
+	var lintInput LintInput
+	if err := json.Unmarshal(input, &lintInput); err != nil {
+		return "", fmt.Errorf("lint input: %w", err)
+	}
+	packages := lintInput.Packages
+	if len(packages) == 0 {
+		packages = []string{"./..."}
+	}
+	contextLines := lintInput.ContextLines
+	if contextLines == 0 {
+		contextLines = defaultGoCheckContext
+	}
+	maxIssues := lintInput.MaxIssues
+	if maxIssues <= 0 {
+		maxIssues = defaultLintMaxIssues
+	}
+	workDir := lintInput.WorkingDir
+	if workDir == "" {
+		workDir = "."
+	}
+	var changed map[string]bool
+	if lintInput.ChangedOnly {
+		touched := Checkpoints.Touched()
+		if len(touched) == 0 {
+			return "No files have been changed in this session; nothing to lint.", nil
+		}
+		changed = map[string]bool{}
+		for _, p := range touched {
+			changed[p] = true
+		}
+	}
+
+	var diags []diagnostic
+	var notes []string
+	used, linted, fallback := "go vet", false, ""
+	if linter, ok := findLinter(workDir); ok {
+		found, err := runGolangci(linter, workDir, packages)
+		if err == nil {
+			diags, used, linted = found, linter.name, true
+		} else {
+			used = fmt.Sprintf("go vet (%s failed)", linter.name)
+			fallback = fmt.Sprintf("%s did not produce a report, fell back to go vet: %v", linter.name, err)
+		}
+	}
+	if !linted {
+		out, err := runGoTool(workDir, append([]string{"vet"}, packages...)...)
+		if err != nil {
+			return "", fmt.Errorf("lint: %w", err)
+		}
+		var other []string
+		diags, other = parseDiagnostics(out, workDir, "vet", "warning")
+		if len(other) > 0 {
+			notes = append(notes, "Other output:\n"+strings.Join(dedupe(other), "\n"))
+		}
+	}
+
+	if changed != nil {
+		var kept []diagnostic
+		for _, d := range diags {
+			if changed[d.file] {
+				kept = append(kept, d)
+			}
+		}
+		diags = kept
+	}
+	diags = mergeDiagnostics(nil, diags)
+	header := fmt.Sprintf("%s: %s", used, countLabel(len(diags), "issue"))
+	if changed != nil {
+		header += fmt.Sprintf(" in %s changed this session", countLabel(len(changed), "file"))
+	}
+	if len(diags) > maxIssues {
+		header += fmt.Sprintf(" (showing the first %d)", maxIssues)
+		diags = diags[:maxIssues]
+	}
+	result := header
+	if fallback != "" {
+		// Before the issues, so a long report cannot push the reason out of the capped output.
+		result += "\n" + fallback
+	}
+	if len(diags) > 0 {
+		result += "\n\n" + formatDiagnostics(diags, contextLines)
+	} else {
+		result += "\nNo problems found."
+	}
+	for _, note := range notes {
+		result += "\n\n" + note
+	}
+	return capOutput(result, 0), nil
+}
+
+// findLinter looks for a go-lint script in workDir or its module root, then for a golangci-lint
+// configuration with golangci-lint installed. ok is false when only go vet is available.
+func findLinter(workDir string) (linterCommand, bool) {
+	workDir, err := filepath.Abs(workDir)
+	if err != nil {
+		return linterCommand{}, false
+	}
+	dirs := []string{workDir}
+	if root := findGoModDir(workDir); root != "" && root != workDir {
+		dirs = append(dirs, root)
+	}
+	config, configDir := "", ""
+	for _, dir := range dirs {
+		for _, name := range golangciConfigs {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil && config == "" {
+				config, configDir = filepath.Join(dir, name), dir
+			}
+		}
+	}
+	// golangci-lint v2 takes a different output flag and reports paths relative to its configuration.
+	jsonArgs, baseDir := []string{"--out-format=json"}, workDir
+	if content, err := os.ReadFile(config); err == nil && golangciV2Config.Match(content) {
+		jsonArgs, baseDir = []string{"--output.json.path=stdout"}, configDir
+	}
+	for _, dir := range dirs {
+		script := filepath.Join(dir, "go-lint")
+		if info, err := os.Stat(script); err == nil && info.Mode().IsRegular() {
+			name := "golangci-lint via " + displayPath(script)
+			if dir == workDir {
+				name = "golangci-lint via ./go-lint"
+			}
+			return linterCommand{name: name, program: "sh", args: append([]string{script}, jsonArgs...), baseDir: baseDir}, true
+		}
+	}
+	if config == "" {
+		return linterCommand{}, false
+	}
+	program, err := exec.LookPath("golangci-lint")
+	if err != nil {
+		return linterCommand{}, false
+	}
+	return linterCommand{name: "golangci-lint", program: program, args: append([]string{"run"}, jsonArgs...), baseDir: baseDir}, true
+}
+
+// runGolangci runs a golangci-lint command and parses its JSON report into diagnostics. Issues make
+// golangci-lint exit non-zero, so the exit status is ignored as long as a report was printed.
+func runGolangci(linter linterCommand, workDir string, packages []string) ([]diagnostic, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), lintTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, linter.program, append(append([]string{}, linter.args...), packages...)...)
+	cmd.Dir = workDir
+	// Killing only sh or go run would leave golangci-lint running and holding the output pipes open.
+	startInProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd) }
+	cmd.WaitDelay = commandKillGracePeriod
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+	if errors.Is(runErr, exec.ErrWaitDelay) {
+		killProcessGroup(cmd)
+		runErr = nil
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("timed out after %s", lintTimeout)
+	}
+	if _, ok := runErr.(*exec.ExitError); runErr != nil && !ok {
+		return nil, runErr
+	}
+	var report struct {
+		Issues []golangciIssue
+	}
+	out := stdout.Bytes()
+	start := bytes.Index(out, []byte(`{"Issues"`))
+	if start < 0 || json.NewDecoder(bytes.NewReader(out[start:])).Decode(&report) != nil {
+		msg := strings.TrimSpace(stderr.String() + "\n" + stdout.String())
+		if lines := strings.Split(msg, "\n"); len(lines) > 10 {
+			msg = strings.Join(lines[len(lines)-10:], "\n")
+		}
+		if msg == "" && runErr != nil {
+			msg = runErr.Error()
+		}
+		return nil, fmt.Errorf("no JSON report in its output:\n%s", msg)
+	}
+	diags := make([]diagnostic, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		severity := issue.Severity
+		if severity == "" {
+			severity = "warning"
+		}
+		file := issue.Pos.Filename
+		if !filepath.IsAbs(file) {
+			if _, err := os.Stat(filepath.Join(linter.baseDir, file)); err == nil {
+				file = filepath.Join(linter.baseDir, file)
+			} else {
+				file = filepath.Join(workDir, file)
+			}
+		}
+		diags = append(diags, diagnostic{
+			file:     diagnosticPath(file, ""),
+			line:     issue.Pos.Line,
+			column:   issue.Pos.Column,
+			severity: severity,
+			source:   issue.FromLinter,
+			message:  issue.Text,
+		})
+	}
+	return diags, nil
+}
@@ -13,14 +13,15 @@ import (
 // ListFilesDefinition is the tool that lists files in a given directory.
 var ListFilesDefinition = ToolDefinition{
 	Name:        "listFiles",
-	Description: "List all files and directories at the given path. Use this when you want to see what files exist in a directory. Pass a directory path (relative to the working directory).",
+	Description: "List all files and directories at the given path. Use this when you want to see what files exist in a directory. Pass a directory path (relative to the working directory). Set showTypes to annotate files with text/binary, encoding and size.",
 	InputSchema: ListFilesInputSchema,
 	Function:    ListFiles,
 }
 
 // ListFilesInput is the JSON shape for the listFiles tool.
 type ListFilesInput struct {
-	Path string `json:"path" jsonschema_description:"The relative path of a directory in the working directory."`
+	Path      string `json:"path" jsonschema_description:"The relative path of a directory in the working directory."`
+	ShowTypes bool   `json:"showTypes" jsonschema_description:"If true, annotate each file with its content type and size, e.g. [text utf-8, 4.2 KB] or [binary (NUL bytes), 1.3 MB]."`
 }
 
 // ListFilesInputSchema is the Anthropic tool input schema for listFiles.
@@ -42,9 +43,20 @@ func ListFiles(input json.RawMessage) (string, error) {
 		name := e.Name()
 		if e.IsDir() {
 			name = name + "/"
+		} else if listFilesInput.ShowTypes {
+			name = annotateType(name, filepath.Join(path, name))
 		}
 		names = append(names, name)
 	}
 	sort.Strings(names)
 	return strings.Join(names, "\n"), nil
 }
+
+// annotateType appends the content classification of the file at path to name, when it can be read.
+func annotateType(name, path string) string {
+	info, err := classifyFile(path)
+	if err != nil {
+		return name
+	}
+	return fmt.Sprintf("%s  [%s]", name, info)
+}
@@ -13,21 +13,23 @@ import (
 // ListFilesRecursiveDefinition is the tool that lists files under a directory recursively.
 var ListFilesRecursiveDefinition = ToolDefinition{
 	Name:        "listFilesRecursive",
-	Description: "List all files and directories under a directory recursively, optionally limited by max depth. Use to see the full tree. Entries use trailing / for directories.",
+	Description: "List all files and directories under a directory recursively, optionally limited by max depth. Use to see the full tree. Entries use trailing / for directories. Entries ignored by .gitignore or .agentignore and directories like .git and node_modules are skipped unless includeIgnored is set. Set showTypes to annotate files with text/binary, encoding and size.",
 	InputSchema: ListFilesRecursiveInputSchema,
 	Function:    ListFilesRecursive,
 }
 
 // ListFilesRecursiveInput is the JSON shape for the listFilesRecursive tool.
 type ListFilesRecursiveInput struct {
-	RootPath string `json:"rootPath" jsonschema_description:"Directory to list; default is current directory (.)."`
-	MaxDepth int    `json:"maxDepth" jsonschema_description:"Optional maximum depth (0 or omit = unlimited). Depth 1 is immediate children only."`
+	RootPath       string `json:"rootPath" jsonschema_description:"Directory to list; default is current directory (.)."`
+	MaxDepth       int    `json:"maxDepth" jsonschema_description:"Optional maximum depth (0 or omit = unlimited). Depth 1 is immediate children only."`
+	IncludeIgnored bool   `json:"includeIgnored" jsonschema_description:"If true, also list entries ignored by .gitignore/.agentignore and junk directories such as node_modules (.git is always skipped)."`
+	ShowTypes      bool   `json:"showTypes" jsonschema_description:"If true, annotate each file with its content type and size, e.g. [text utf-8, 4.2 KB]."`
 }
 
 // ListFilesRecursiveInputSchema is the Anthropic tool input schema for listFilesRecursive.
 var ListFilesRecursiveInputSchema = GenerateSchema[ListFilesRecursiveInput]()
 
-// ListFilesRecursive implements the listFilesRecursive tool: WalkDir and collect paths; apply maxDepth if set.
+// ListFilesRecursive implements the listFilesRecursive tool: walk the tree and collect paths; apply maxDepth if set.
 func ListFilesRecursive(input json.RawMessage) (string, error) {
 	var listFilesRecursiveInput ListFilesRecursiveInput
 	if err := json.Unmarshal(input, &listFilesRecursiveInput); err != nil {
@@ -47,17 +49,11 @@ func ListFilesRecursive(input json.RawMessage) (string, error) {
 		return "", fmt.Errorf("listFilesRecursive: rootPath must be a directory: %s", rootPath)
 	}
 	var entries []string
-	err = filepath.WalkDir(rootPath, func(path string, d os.DirEntry, walkErr error) error {
-		if walkErr != nil {
-			return walkErr
-		}
+	err = walkTree(rootPath, listFilesRecursiveInput.IncludeIgnored, func(path string, d os.DirEntry) error {
 		rel, err := filepath.Rel(rootPath, path)
 		if err != nil {
 			return nil
 		}
-		if rel == "." {
-			return nil
-		}
 		if maxDepth > 0 {
 			depth := strings.Count(rel, string(os.PathSeparator))
 			if d.IsDir() {
@@ -70,6 +66,8 @@ func ListFilesRecursive(input json.RawMessage) (string, error) {
 		}
 		if d.IsDir() {
 			entries = append(entries, path+"/")
+		} else if listFilesRecursiveInput.ShowTypes {
+			entries = append(entries, annotateType(path, path))
 		} else {
 			entries = append(entries, path)
 		}
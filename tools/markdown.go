@@ -0,0 +1,491 @@
+// Package tools provides the HTML to Markdown conversion behind fetchHtml's readable mode.
+package tools
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// boilerplateSelector matches elements that are never part of a page's main content.
+const boilerplateSelector = "script, style, noscript, template, svg, canvas, iframe, object, embed, form, button, input, select, textarea, " +
+	"nav, aside, header, footer, dialog, " +
+	`[role="navigation"], [role="banner"], [role="contentinfo"], [role="complementary"], [role="search"], [role="dialog"], [aria-hidden="true"], [hidden]`
+
+// boilerplateName matches class and id values of navigation, ads, share bars, cookie notices and the like.
+var boilerplateName = regexp.MustCompile(`(?i)(^|[-_ ])(nav|navbar|menu|sidebar|footer|masthead|breadcrumbs?|cookies?|consent|banner|ads?|advert\w*|promo|sponsor\w*|share|social|comments?|related|popup|modal|newsletter|subscribe|skip-link|toc-toggle)($|[-_ ])`)
+
+// mainContentSelector lists elements that usually hold a page's main content, best first.
+const mainContentSelector = `main, [role="main"], article, #content, #main-content, #main, .content, .post, .entry-content`
+
+// readablePage is a page converted by htmlToMarkdown.
+type readablePage struct {
+	title    string
+	markdown string
+}
+
+// htmlToMarkdown extracts the main content of doc, drops boilerplate and renders it as Markdown.
+// Relative links and images are resolved against base.
+func htmlToMarkdown(doc *goquery.Document, base *url.URL) readablePage {
+	page := readablePage{title: collapseSpace(doc.Find("title").First().Text())}
+	if h1 := collapseSpace(doc.Find("h1").First().Text()); page.title == "" {
+		page.title = h1
+	}
+	removeBoilerplate(doc)
+	root := mainContent(doc)
+	if root == nil {
+		return page
+	}
+	c := markdownConverter{base: base}
+	page.markdown = strings.TrimSpace(c.blocks(root, "\n\n"))
+	return page
+}
+
+// removeBoilerplate deletes scripts, styles, forms, navigation, sidebars and elements whose class
+// or id looks like boilerplate, unless they hold the main content.
+func removeBoilerplate(doc *goquery.Document) {
+	doc.Find(boilerplateSelector).Each(func(_ int, s *goquery.Selection) {
+		if s.Find("main, article").Length() > 0 {
+			return
+		}
+		if goquery.NodeName(s) == "header" && s.ParentsFiltered("main, article").Length() > 0 {
+			return // an article's header holds its title
+		}
+		s.Remove()
+	})
+	doc.Find("[class], [id]").Each(func(_ int, s *goquery.Selection) {
+		switch goquery.NodeName(s) {
+		case "html", "body", "main", "article":
+			return
+		}
+		class, _ := s.Attr("class")
+		id, _ := s.Attr("id")
+		if !boilerplateName.MatchString(class) && !boilerplateName.MatchString(id) {
+			return
+		}
+		if s.Find(mainContentSelector).Length() > 0 || s.Find("p").Length() > 5 {
+			return // a wrapper of the content rather than boilerplate
+		}
+		s.Remove()
+	})
+}
+
+// mainContent returns the element holding the page's main content: the largest of the usual
+// content elements, else the element whose paragraphs hold the most text, else the body.
+func mainContent(doc *goquery.Document) *html.Node {
+	var best *html.Node
+	bestLen := 0
+	doc.Find(mainContentSelector).Each(func(_ int, s *goquery.Selection) {
+		if n := len(collapseSpace(s.Text())); n > bestLen {
+			best, bestLen = s.Nodes[0], n
+		}
+	})
+	if best != nil && bestLen >= 200 {
+		return best
+	}
+
+	// Score each paragraph's parent with its text and the grandparent with half of it, so the
+	// container of the article body wins over a single long paragraph.
+	scores := map[*html.Node]int{}
+	doc.Find("p, pre").Each(func(_ int, s *goquery.Selection) {
+		n := len(collapseSpace(s.Text()))
+		if n < 25 {
+			return
+		}
+		p := s.Nodes[0].Parent
+		if p == nil {
+			return
+		}
+		scores[p] += n
+		if p.Parent != nil {
+			scores[p.Parent] += n / 2
+		}
+	})
+	best, bestScore := nil, 0
+	for n, score := range scores {
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best != nil && bestScore >= 250 {
+		return best
+	}
+	if body := doc.Find("body"); body.Length() > 0 {
+		return body.Nodes[0]
+	}
+	if len(doc.Nodes) > 0 {
+		return doc.Nodes[0]
+	}
+	return nil
+}
+
+// inlineElements are rendered inside the surrounding paragraph.
+var inlineElements = wordSet("a", "abbr", "b", "bdi", "bdo", "br", "cite", "code", "data", "del", "dfn", "em", "font", "i", "img",
+	"ins", "kbd", "label", "mark", "q", "s", "samp", "small", "span", "strike", "strong", "sub", "sup", "time", "tt", "u", "var", "wbr")
+
+// markdownConverter renders HTML nodes as Markdown.
+type markdownConverter struct {
+	base *url.URL
+}
+
+// blocks renders the children of n as Markdown blocks joined by sep; runs of inline content
+// between block elements become paragraphs.
+func (c *markdownConverter) blocks(n *html.Node, sep string) string {
+	var out []string
+	var inline strings.Builder
+	flush := func() {
+		if p := trimLines(inline.String()); p != "" {
+			out = append(out, p)
+		}
+		inline.Reset()
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		switch {
+		case child.Type == html.TextNode:
+			inline.WriteString(collapseSpaceKeepEdges(child.Data))
+		case child.Type != html.ElementNode:
+		case inlineElements[child.Data]:
+			inline.WriteString(c.inline(child))
+		default:
+			flush()
+			if b := c.block(child); strings.TrimSpace(b) != "" {
+				out = append(out, b)
+			}
+		}
+	}
+	flush()
+	return strings.Join(out, sep)
+}
+
+// block renders one block-level element.
+func (c *markdownConverter) block(n *html.Node) string {
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := strings.TrimSpace(strings.ReplaceAll(c.inlineChildren(n), "\n", " "))
+		if text == "" {
+			return ""
+		}
+		return strings.Repeat("#", int(n.Data[1]-'0')) + " " + text
+	case "p":
+		return trimLines(c.inlineChildren(n))
+	case "ul", "ol":
+		return c.list(n)
+	case "pre":
+		return c.codeBlock(n)
+	case "blockquote":
+		return prefixLines(c.blocks(n, "\n\n"), "> ")
+	case "table":
+		return c.table(n)
+	case "hr":
+		return "---"
+	case "dt":
+		if text := trimLines(c.inlineChildren(n)); text != "" {
+			return "**" + text + "**"
+		}
+		return ""
+	case "dl":
+		return c.blocks(n, "\n")
+	default:
+		return c.blocks(n, "\n\n")
+	}
+}
+
+// inlineChildren renders the children of n as inline Markdown; nested blocks are flattened.
+func (c *markdownConverter) inlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case html.TextNode:
+			b.WriteString(collapseSpaceKeepEdges(child.Data))
+		case html.ElementNode:
+			if inlineElements[child.Data] {
+				b.WriteString(c.inline(child))
+			} else {
+				b.WriteString(" " + c.inlineChildren(child) + " ")
+			}
+		}
+	}
+	return b.String()
+}
+
+// inline renders one inline element.
+func (c *markdownConverter) inline(n *html.Node) string {
+	switch n.Data {
+	case "br":
+		return "\n"
+	case "a":
+		text := strings.TrimSpace(strings.ReplaceAll(c.inlineChildren(n), "\n", " "))
+		href := c.resolve(htmlAttr(n, "href"))
+		if text == "" || href == "" || strings.HasPrefix(htmlAttr(n, "href"), "#") {
+			return text
+		}
+		return "[" + text + "](" + href + ")"
+	case "img":
+		alt := collapseSpace(htmlAttr(n, "alt"))
+		src := c.resolve(htmlAttr(n, "src"))
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return alt
+		}
+		return "![" + alt + "](" + src + ")"
+	case "strong", "b":
+		return wrapInline(c.inlineChildren(n), "**")
+	case "em", "i", "cite", "dfn", "var":
+		return wrapInline(c.inlineChildren(n), "*")
+	case "del", "s", "strike":
+		return wrapInline(c.inlineChildren(n), "~~")
+	case "code", "kbd", "samp", "tt":
+		text := collapseSpace(nodeText(n))
+		if text == "" {
+			return ""
+		}
+		fence := "`"
+		if strings.Contains(text, "`") {
+			fence = "``"
+			text = " " + text + " "
+		}
+		return fence + text + fence
+	default:
+		return c.inlineChildren(n)
+	}
+}
+
+// list renders a ul or ol, indenting nested content under each item.
+func (c *markdownConverter) list(n *html.Node) string {
+	ordered := n.Data == "ol"
+	number := 1
+	if start, err := strconv.Atoi(htmlAttr(n, "start")); err == nil && ordered {
+		number = start
+	}
+	var items []string
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+		var content string
+		switch child.Data {
+		case "li":
+			content = c.blocks(child, "\n")
+		case "ul", "ol":
+			// A list nested directly in a list belongs to the previous item.
+			if nested := c.list(child); nested != "" && len(items) > 0 {
+				items[len(items)-1] += "\n" + prefixLines(nested, "   ")
+			}
+			continue
+		default:
+			content = c.blocks(child, "\n")
+		}
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+strings.ReplaceAll(content, "\n", "\n"+indent))
+	}
+	return strings.Join(items, "\n")
+}
+
+// codeBlock renders a pre element as a fenced code block, keeping its whitespace and taking the
+// language from a language-* or lang-* class.
+func (c *markdownConverter) codeBlock(n *html.Node) string {
+	code := strings.Trim(nodeText(n), "\n")
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+	lang := codeLanguage(htmlAttr(n, "class"))
+	if lang == "" {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode && child.Data == "code" {
+				lang = codeLanguage(htmlAttr(child, "class"))
+				break
+			}
+		}
+	}
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+var codeLanguageClass = regexp.MustCompile(`(?:^|\s)(?:language|lang)-([\w+#.-]+)`)
+
+// codeLanguage returns the language named by a language-* or lang-* class, or "".
+func codeLanguage(class string) string {
+	if m := codeLanguageClass.FindStringSubmatch(class); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// table renders a table as a Markdown table with its first row as the header. Layout tables that
+// contain other tables or have a single cell are rendered as their content instead.
+func (c *markdownConverter) table(n *html.Node) string {
+	var rows [][]string
+	nested := false
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.Data {
+			case "thead", "tbody", "tfoot":
+				walk(child)
+			case "tr":
+				var row []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type != html.ElementNode || (cell.Data != "td" && cell.Data != "th") {
+						continue
+					}
+					if goquery.NewDocumentFromNode(cell).Find("table").Length() > 0 {
+						nested = true
+					}
+					text := strings.Join(strings.Fields(c.inlineChildren(cell)), " ")
+					text = strings.ReplaceAll(text, "|", `\|`)
+					row = append(row, text)
+					if span, err := strconv.Atoi(htmlAttr(cell, "colspan")); err == nil {
+						for i := 1; i < min(span, 50); i++ {
+							row = append(row, "")
+						}
+					}
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+			}
+		}
+	}
+	walk(n)
+	if nested || len(rows) == 0 || (len(rows) == 1 && len(rows[0]) == 1) {
+		return c.blocks(n, "\n\n")
+	}
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	var b strings.Builder
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", width) + "\n")
+		}
+	}
+	if caption := goquery.NewDocumentFromNode(n).Find("caption").First(); caption.Length() > 0 {
+		if text := collapseSpace(caption.Text()); text != "" {
+			return "*" + text + "*\n\n" + strings.TrimSuffix(b.String(), "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// resolve returns href as an absolute URL, or "" for script links.
+func (c *markdownConverter) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return ""
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	if c.base != nil {
+		u = c.base.ResolveReference(u)
+	}
+	return u.String()
+}
+
+// htmlAttr returns the value of n's attribute key, or "".
+func htmlAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// nodeText returns the text under n as written, with br elements as newlines.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.Type == html.ElementNode && n.Data == "br":
+			b.WriteString("\n")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// wrapInline surrounds text with the emphasis marker, keeping outer spaces outside it.
+func wrapInline(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	lead := text[:len(text)-len(strings.TrimLeft(text, " \n"))]
+	trail := text[len(strings.TrimRight(text, " \n")):]
+	return lead + marker + trimmed + marker + trail
+}
+
+// collapseSpace replaces runs of whitespace with one space and trims the ends.
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// collapseSpaceKeepEdges is like collapseSpace but keeps one space where s starts or ends with
+// whitespace, so words of adjacent inline elements stay apart.
+func collapseSpaceKeepEdges(s string) string {
+	collapsed := collapseSpace(s)
+	if collapsed == "" {
+		if s != "" {
+			return " "
+		}
+		return ""
+	}
+	if strings.TrimLeft(s, " \t\r\n\f") != s {
+		collapsed = " " + collapsed
+	}
+	if strings.TrimRight(s, " \t\r\n\f") != s {
+		collapsed += " "
+	}
+	return collapsed
+}
+
+// trimLines trims spaces around every line of s, collapses doubled spaces and drops blank lines.
+func trimLines(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = collapseSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// prefixLines puts prefix in front of every line of s.
+func prefixLines(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(prefix+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
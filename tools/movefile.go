@@ -43,6 +43,9 @@ func MoveFile(input json.RawMessage) (string, error) {
 	if info.IsDir() {
 		return "", fmt.Errorf("moveFile: source is a directory, not a file: %s", fromPath)
 	}
+	if err := snapshotForWrite(fromPath, toPath); err != nil {
+		return "", err
+	}
 	err = os.Rename(fromPath, toPath)
 	if err == nil {
 		return fmt.Sprintf("Moved %s to %s", fromPath, toPath), nil
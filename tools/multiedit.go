@@ -0,0 +1,93 @@
+// Package tools provides the multi_edit tool for the agent.
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MultiEditDefinition is the tool that applies several string-replacement edits across files atomically.
+var MultiEditDefinition = ToolDefinition{
+	Name:        "multi_edit",
+	Description: "Apply a list of edits across one or more existing files as a single all-or-nothing change. Each edit has the same fields and matching rules as edit_file (path, old_string, new_string, replace_all, expected_count, start_line, end_line); edits to the same file are applied in order, each against the result of the previous one. Every edit is validated against the current contents before anything is written; if any edit fails, no file is changed and the failing edit is reported. Returns a combined unified diff.",
+	InputSchema: MultiEditInputSchema,
+	Function:    MultiEdit,
+}
+
+// MultiEditInput is the JSON shape for the multi_edit tool.
+type MultiEditInput struct {
+	Edits []EditFileInput `json:"edits" jsonschema_description:"The edits to apply, in order."`
+}
+
+// MultiEditInputSchema is the Anthropic tool input schema for multi_edit.
+var MultiEditInputSchema = GenerateSchema[MultiEditInput]()
+
+// MultiEdit implements the multi_edit tool: applies all edits in memory, then writes every touched file atomically.
+func MultiEdit(input json.RawMessage) (string, error) {
+	var in MultiEditInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return "", fmt.Errorf("multi_edit input: %w", err)
+	}
+	if len(in.Edits) == 0 {
+		return "", fmt.Errorf("multi_edit: edits is required")
+	}
+
+	var order []string
+	originals := map[string]string{}
+	current := map[string]string{}
+	var notes []string
+	for i, edit := range in.Edits {
+		path := filepath.Clean(edit.Path)
+		if _, ok := current[path]; !ok {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("multi_edit: edit %d (%s): %w; no files were changed", i+1, path, err)
+			}
+			originals[path] = string(content)
+			current[path] = string(content)
+			order = append(order, path)
+		}
+		updated, outcome, err := applyEdit(current[path], edit)
+		if err != nil {
+			return "", fmt.Errorf("multi_edit: edit %d (%s): %w; no files were changed", i+1, path, err)
+		}
+		current[path] = updated
+		notes = append(notes, fmt.Sprintf("edit %d: %s: replaced %d occurrence(s)%s", i+1, path, outcome.count, outcome.describe()))
+	}
+
+	for _, path := range order {
+		formatted, goNote, err := prepareGoWrite(path, current[path])
+		if err != nil {
+			return "", fmt.Errorf("multi_edit: %w; no files were changed", err)
+		}
+		current[path] = formatted
+		if goNote != "" {
+			notes = append(notes, goNote)
+		}
+	}
+
+	var writes []pendingWrite
+	for _, path := range order {
+		if current[path] != originals[path] {
+			writes = append(writes, pendingWrite{path: path, content: []byte(current[path])})
+		}
+	}
+	if len(writes) == 0 {
+		return "No changes: the edits leave every file unchanged.", nil
+	}
+	if err := Checkpoints.Snapshot(order...); err != nil {
+		return "", err
+	}
+	if err := writeFilesAtomically(writes); err != nil {
+		return "", fmt.Errorf("multi_edit: %w", err)
+	}
+
+	var diffs strings.Builder
+	for _, w := range writes {
+		diffs.WriteString(reportDiff("multi_edit", w.path, originals[w.path], current[w.path], true, false))
+	}
+	return fmt.Sprintf("Applied %d edit(s) to %d file(s)\n%s\n\n%s", len(in.Edits), len(writes), strings.Join(notes, "\n"), diffForResult(diffs.String())), nil
+}
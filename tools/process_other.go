@@ -0,0 +1,21 @@
+//go:build !unix
+
+package tools
+
+import "os/exec"
+
+// startInProcessGroup is a no-op where process groups are not available.
+func startInProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills cmd's process; its children are not reached on this platform.
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
+
+// terminateProcessGroup kills cmd's process; there is no gentler signal on this platform.
+func terminateProcessGroup(cmd *exec.Cmd) error {
+	return killProcessGroup(cmd)
+}
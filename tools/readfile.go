@@ -4,13 +4,22 @@ package tools
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"strings"
 )
 
+// readFileMaxBytes is the largest file readFile returns whole; bigger files get a summary instead.
+const readFileMaxBytes = 100 * 1024
+
+// readFilePreviewLines is how many leading lines the summary of a large file shows.
+const readFilePreviewLines = 40
+
+// readFilePreviewBytes caps how much of a large file is read for its preview.
+const readFilePreviewBytes = 64 * 1024
+
 // ReadFileDefinition is the tool that reads file contents by relative path.
 var ReadFileDefinition = ToolDefinition{
 	Name:        "readFile",
-	Description: "Read the contents of a given relative file path. Use this when you want to see what's inside a file. Do not use this with directory names.",
+	Description: "Read the contents of a given relative file path. Use this when you want to see what's inside a file. Do not use this with directory names. Binary files are refused; files over 100 KB return a summary (size, line count, first lines) instead, so use readFileLines or grepInFile for them.",
 	InputSchema: ReadFileInputSchema,
 	Function:    ReadFile,
 }
@@ -23,15 +32,32 @@ type ReadFileInput struct {
 // ReadFileInputSchema is the Anthropic tool input schema for readFile.
 var ReadFileInputSchema = GenerateSchema[ReadFileInput]()
 
-// ReadFile implements the readFile tool: reads the file at the given path and returns its contents or an error.
+// ReadFile implements the readFile tool: reads the file at the given path and returns its contents,
+// a summary for large files, or an error for binary files.
 func ReadFile(input json.RawMessage) (string, error) {
 	var readFileInput ReadFileInput
 	if err := json.Unmarshal(input, &readFileInput); err != nil {
 		return "", fmt.Errorf("readFile input: %w", err)
 	}
-	content, err := os.ReadFile(readFileInput.Path)
+	path := readFileInput.Path
+	info, err := classifyFile(path)
+	if err != nil {
+		return "", err
+	}
+	if info.binary {
+		return "", fmt.Errorf("readFile: %s is a binary file (%s); its content is not returned. Use fileInfo for metadata", path, info)
+	}
+	if info.size <= readFileMaxBytes {
+		content, _, err := readText(path)
+		if err != nil {
+			return "", err
+		}
+		return content, nil
+	}
+	preview, lineCount, err := readTextPreview(path, info.encoding, readFilePreviewLines, readFilePreviewBytes)
 	if err != nil {
 		return "", err
 	}
-	return string(content), nil
+	return fmt.Sprintf("[%s is large (%s, %d lines); showing the first %d lines. Use readFileLines with startLine/endLine to read other parts, or grepInFile to locate what you need.]\n%s",
+		path, info, lineCount, len(preview), strings.Join(preview, "\n")), nil
 }
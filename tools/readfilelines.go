@@ -4,7 +4,6 @@ package tools
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 )
 
@@ -41,11 +40,14 @@ func ReadFileLines(input json.RawMessage) (string, error) {
 	if end < start {
 		return "", fmt.Errorf("readFileLines: endLine must be >= startLine")
 	}
-	content, err := os.ReadFile(path)
+	content, info, err := readText(path)
 	if err != nil {
 		return "", err
 	}
-	lines := strings.Split(string(content), "\n")
+	if info.binary {
+		return "", fmt.Errorf("readFileLines: %s is a binary file (%s)", path, info)
+	}
+	lines := strings.Split(content, "\n")
 	if start > len(lines) {
 		return "", fmt.Errorf("readFileLines: startLine %d is beyond file length %d", start, len(lines))
 	}
@@ -42,6 +42,9 @@ func RemoveDirectory(input json.RawMessage) (string, error) {
 	if !info.IsDir() {
 		return "", fmt.Errorf("removeDirectory: path is not a directory: %s", path)
 	}
+	if err := Checkpoints.Snapshot(path); err != nil {
+		return "", err
+	}
 	if removeDirectoryInput.Recursive {
 		if err := os.RemoveAll(path); err != nil {
 			return "", err
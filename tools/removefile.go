@@ -40,6 +40,9 @@ func RemoveFile(input json.RawMessage) (string, error) {
 	if info.IsDir() {
 		return "", fmt.Errorf("remove_file: path is a directory, not a file: %s", path)
 	}
+	if err := Checkpoints.Snapshot(path); err != nil {
+		return "", err
+	}
 	if err := os.Remove(path); err != nil {
 		return "", err
 	}
@@ -2,30 +2,47 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"sort"
 	"strings"
+	"time"
 )
 
 // RunCommandDefinition is the tool that runs a shell command and returns output and exit code.
 var RunCommandDefinition = ToolDefinition{
 	Name:        "runCommand",
-	Description: "Run a shell command and return stdout, stderr, and exit code. Use for builds, tests, linters, or any shell command. Working directory is optional.",
+	Description: "Run a shell command and return stdout, stderr, exit code and duration. Use for builds, tests, linters, or any shell command. The command is killed (with its child processes) after timeoutSeconds, default 120; stdin is empty unless given, so interactive prompts fail instead of hanging. Long output keeps its beginning and end. Working directory and environment overrides are optional.",
 	InputSchema: RunCommandInputSchema,
 	Function:    RunCommand,
 }
 
 // RunCommandInput is the JSON shape for the runCommand tool.
 type RunCommandInput struct {
-	Command    string `json:"command" jsonschema_description:"The shell command to run (e.g. go build, go test, ./go-lint)."`
-	WorkingDir string `json:"workingDir" jsonschema_description:"Optional working directory for the command; default is current directory."`
+	Command        string            `json:"command" jsonschema_description:"The shell command to run (e.g. go generate, make); use go_check, go_test and lint to build, test and lint Go code."`
+	WorkingDir     string            `json:"workingDir" jsonschema_description:"Optional working directory for the command; default is current directory."`
+	TimeoutSeconds int               `json:"timeoutSeconds" jsonschema_description:"Kill the command after this many seconds; default 120, maximum 3600."`
+	Env            map[string]string `json:"env" jsonschema_description:"Optional environment variables to set or override, e.g. {\"CGO_ENABLED\": \"0\"}."`
+	Stdin          string            `json:"stdin" jsonschema_description:"Optional content passed to the command on standard input."`
+	MaxOutputBytes int               `json:"maxOutputBytes" jsonschema_description:"Bytes of stdout and of stderr kept (first and last halves); default 16000."`
 }
 
 // RunCommandInputSchema is the Anthropic tool input schema for runCommand.
 var RunCommandInputSchema = GenerateSchema[RunCommandInput]()
 
-// RunCommand implements the runCommand tool: runs the command via sh -c and returns exit code, stdout, stderr.
+const (
+	defaultCommandTimeout  = 120 * time.Second
+	maxCommandTimeout      = time.Hour
+	defaultCommandOutput   = 16_000
+	commandKillGracePeriod = 2 * time.Second
+)
+
+// RunCommand implements the runCommand tool: runs the command via sh -c in its own process group
+// and returns exit code, duration, stdout and stderr.
 func RunCommand(input json.RawMessage) (string, error) {
 	var runCommandInput RunCommandInput
 	if err := json.Unmarshal(input, &runCommandInput); err != nil {
@@ -35,18 +52,130 @@ func RunCommand(input json.RawMessage) (string, error) {
 	if command == "" {
 		return "", fmt.Errorf("runCommand: command is required")
 	}
-	cmd := exec.Command("sh", "-c", command)
+	timeout := defaultCommandTimeout
+	if runCommandInput.TimeoutSeconds > 0 {
+		timeout = min(time.Duration(runCommandInput.TimeoutSeconds)*time.Second, maxCommandTimeout)
+	}
+	limit := runCommandInput.MaxOutputBytes
+	if limit <= 0 {
+		limit = defaultCommandOutput
+	}
+	if _, err := checkCommandRisk("runCommand", command, runCommandInput.WorkingDir); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
 	if runCommandInput.WorkingDir != "" {
 		cmd.Dir = runCommandInput.WorkingDir
 	}
-	stdout, err := cmd.Output()
-	stdoutStr := string(stdout)
+	cmd.Env = commandEnv(runCommandInput.Env)
+	if runCommandInput.Stdin != "" {
+		cmd.Stdin = strings.NewReader(runCommandInput.Stdin)
+	}
+	startInProcessGroup(cmd)
+	if err := sandboxCommand(cmd); err != nil {
+		return "", fmt.Errorf("runCommand: %w", err)
+	}
+	cmd.Cancel = func() error { return killProcessGroup(cmd) }
+	// Children that outlive the shell can hold the output pipes open; stop waiting for them.
+	cmd.WaitDelay = commandKillGracePeriod
+	stdout, stderr := newHeadTailBuffer(limit), newHeadTailBuffer(limit)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	duration := time.Since(start).Round(time.Millisecond)
+	exitCode := 0
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// The shell exited but left children holding its output open: stop them and keep its status.
+		killProcessGroup(cmd)
+		exitCode, err = cmd.ProcessState.ExitCode(), nil
+	}
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			stderrStr := string(exitErr.Stderr)
-			return fmt.Sprintf("exitCode: %d\nstdout:\n%sstderr:\n%s", exitErr.ExitCode(), stdoutStr, stderrStr), nil
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) && ctx.Err() == nil {
+			return "", fmt.Errorf("runCommand: %w", err)
 		}
-		return "", err
+		exitCode = -1
+		if exitErr != nil {
+			exitCode = exitErr.ExitCode()
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "exitCode: %d\nduration: %s\n", exitCode, duration)
+	if ctx.Err() == context.DeadlineExceeded {
+		fmt.Fprintf(&b, "killed: true (timed out after %s)\n", timeout)
+	}
+	if sandbox := sandboxSummary(); sandbox != "" {
+		fmt.Fprintf(&b, "sandbox: %s\n", sandbox)
+	}
+	fmt.Fprintf(&b, "stdout:\n%s", stdout)
+	if stderr.Len() > 0 {
+		fmt.Fprintf(&b, "stderr:\n%s", stderr)
+	}
+	return b.String(), nil
+}
+
+// commandEnv returns the agent's environment with overrides applied, in a stable order.
+func commandEnv(overrides map[string]string) []string {
+	env := os.Environ()
+	keys := make([]string, 0, len(overrides))
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+overrides[k])
+	}
+	return env
+}
+
+// headTailBuffer is an io.Writer that keeps the first and last limit/2 bytes written to it,
+// so long output stays bounded while showing both how it started and how it ended.
+type headTailBuffer struct {
+	half  int
+	head  []byte
+	tail  []byte
+	total int
+}
+
+func newHeadTailBuffer(limit int) *headTailBuffer {
+	return &headTailBuffer{half: max(limit/2, 1)}
+}
+
+func (b *headTailBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+	rest := p
+	if room := b.half - len(b.head); room > 0 {
+		n := min(room, len(rest))
+		b.head = append(b.head, rest[:n]...)
+		rest = rest[n:]
+	}
+	b.tail = append(b.tail, rest...)
+	if len(b.tail) > 2*b.half {
+		b.tail = append(b.tail[:0], b.tail[len(b.tail)-b.half:]...)
+	}
+	return len(p), nil
+}
+
+// Len is the number of bytes written, including any that were dropped.
+func (b *headTailBuffer) Len() int {
+	return b.total
+}
+
+func (b *headTailBuffer) String() string {
+	tail := b.tail
+	if len(tail) > b.half {
+		tail = tail[len(tail)-b.half:]
+	}
+	omitted := b.total - len(b.head) - len(tail)
+	if omitted == 0 {
+		return string(b.head) + string(tail)
 	}
-	return fmt.Sprintf("exitCode: 0\nstdout:\n%s", stdoutStr), nil
+	return strings.ToValidUTF8(string(b.head), "") +
+		fmt.Sprintf("\n[... %d bytes omitted ...]\n", omitted) +
+		strings.ToValidUTF8(string(tail), "")
 }
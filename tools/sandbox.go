@@ -0,0 +1,163 @@
+// Package tools provides the optional namespace sandbox for the commands the agent runs.
+package tools
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SandboxConfig describes the sandbox that runCommand, process_start and shell commands run in
+// when it is enabled: the workspace and Writable paths stay writable, the rest of the filesystem
+// is read-only, /tmp is a private tmpfs and networking is off unless Network is set. Commands run
+// without capabilities and cannot change the mounts.
+type SandboxConfig struct {
+	Workspace    string   `json:"workspace"`
+	Writable     []string `json:"writable,omitempty"`
+	Network      bool     `json:"network,omitempty"`
+	CPUSeconds   int      `json:"cpuSeconds,omitempty"`   // RLIMIT_CPU; 0 for none
+	MemoryMB     int      `json:"memoryMB,omitempty"`     // RLIMIT_AS; 0 for none
+	MaxProcesses int      `json:"maxProcesses,omitempty"` // RLIMIT_NPROC; 0 for none
+}
+
+// Sandbox resource limits used unless overridden in the environment.
+const (
+	defaultSandboxCPUSeconds   = 600
+	defaultSandboxMemoryMB     = 8192
+	defaultSandboxMaxProcesses = 4096
+)
+
+// sandboxInitArg marks the agent binary re-executed as the sandbox init (see SandboxInit).
+const sandboxInitArg = "__agent_sandbox_init__"
+
+var (
+	sandboxMu     sync.Mutex
+	sandboxActive *SandboxConfig
+)
+
+// SandboxConfigFromEnv builds the sandbox configuration from the environment, or returns nil when
+// AGENT_SANDBOX is unset or "off". AGENT_SANDBOX=on enables it with the current directory as the
+// workspace; AGENT_SANDBOX_NETWORK=allow keeps networking; AGENT_SANDBOX_WRITABLE lists extra
+// writable paths (separated like PATH); AGENT_SANDBOX_CPU (seconds), AGENT_SANDBOX_MEMORY (MB) and
+// AGENT_SANDBOX_NPROC override the resource limits, 0 meaning unlimited. The Go build cache stays
+// writable so go build and go test keep working.
+func SandboxConfigFromEnv() (*SandboxConfig, error) {
+	switch mode := os.Getenv("AGENT_SANDBOX"); mode {
+	case "", "off":
+		return nil, nil
+	case "on":
+	default:
+		return nil, fmt.Errorf("AGENT_SANDBOX must be on or off, not %q", mode)
+	}
+	workspace, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	cfg := &SandboxConfig{
+		Workspace:    workspace,
+		Network:      os.Getenv("AGENT_SANDBOX_NETWORK") == "allow",
+		CPUSeconds:   defaultSandboxCPUSeconds,
+		MemoryMB:     defaultSandboxMemoryMB,
+		MaxProcesses: defaultSandboxMaxProcesses,
+	}
+	if cache := goBuildCacheDir(); cache != "" {
+		cfg.Writable = append(cfg.Writable, cache)
+	}
+	for _, p := range filepath.SplitList(os.Getenv("AGENT_SANDBOX_WRITABLE")) {
+		if p != "" {
+			cfg.Writable = append(cfg.Writable, p)
+		}
+	}
+	for name, limit := range map[string]*int{
+		"AGENT_SANDBOX_CPU":    &cfg.CPUSeconds,
+		"AGENT_SANDBOX_MEMORY": &cfg.MemoryMB,
+		"AGENT_SANDBOX_NPROC":  &cfg.MaxProcesses,
+	} {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%s must be a non-negative integer, not %q", name, v)
+			}
+			*limit = n
+		}
+	}
+	return cfg, nil
+}
+
+// goBuildCacheDir returns the Go build cache directory if it exists, without running go env.
+func goBuildCacheDir() string {
+	dir := os.Getenv("GOCACHE")
+	if dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(cache, "go-build")
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return ""
+	}
+	return dir
+}
+
+// SetSandbox runs later commands in the sandbox described by cfg, or outside any sandbox when cfg
+// is nil. It fails if the sandbox cannot be created on this system.
+func SetSandbox(cfg *SandboxConfig) error {
+	if cfg != nil {
+		if err := checkSandbox(*cfg); err != nil {
+			return fmt.Errorf("sandbox: %w", err)
+		}
+	}
+	sandboxMu.Lock()
+	defer sandboxMu.Unlock()
+	sandboxActive = cfg
+	return nil
+}
+
+// currentSandbox returns the active sandbox configuration, or nil.
+func currentSandbox() *SandboxConfig {
+	sandboxMu.Lock()
+	defer sandboxMu.Unlock()
+	return sandboxActive
+}
+
+// sandboxCommand rewrites cmd to run inside the sandbox, if one is enabled. Call it once cmd's
+// program, arguments and directory are set, before starting it.
+func sandboxCommand(cmd *exec.Cmd) error {
+	cfg := currentSandbox()
+	if cfg == nil || cmd.Err != nil {
+		return nil
+	}
+	return wrapSandbox(cmd, *cfg)
+}
+
+// sandboxSummary describes the active sandbox for tool results, or returns "" when there is none.
+func sandboxSummary() string {
+	cfg := currentSandbox()
+	if cfg == nil {
+		return ""
+	}
+	parts := []string{"workspace writable, rest read-only"}
+	if cfg.Network {
+		parts = append(parts, "network allowed")
+	} else {
+		parts = append(parts, "no network")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// SandboxInit must be called first thing in main. When the process was started as the sandbox
+// init, it sets up the sandbox and replaces itself with the sandboxed command, never returning;
+// otherwise it does nothing.
+func SandboxInit() {
+	if len(os.Args) < 2 || os.Args[1] != sandboxInitArg {
+		return
+	}
+	err := runSandboxInit(os.Args[2:])
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(126)
+}
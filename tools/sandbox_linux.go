@@ -0,0 +1,356 @@
+//go:build linux
+
+package tools
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// wrapSandbox makes cmd start the agent binary as the sandbox init in new user, mount and (unless
+// networking is allowed) network namespaces, mapped to the current user; the init then runs the
+// original program.
+func wrapSandbox(cmd *exec.Cmd, cfg SandboxConfig) error {
+	encoded, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	cmd.Args = append([]string{"agent-sandbox", sandboxInitArg, string(encoded), cmd.Path}, cmd.Args...)
+	cmd.Path = "/proc/self/exe"
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS
+	if !cfg.Network {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	// Root inside the namespace keeps the capabilities needed to set up mounts; files it creates
+	// belong to the real user.
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+	return nil
+}
+
+// checkSandbox runs true in the sandbox to make sure namespaces can be created here.
+func checkSandbox(cfg SandboxConfig) error {
+	cmd := exec.Command("true")
+	if err := wrapSandbox(cmd, cfg); err != nil {
+		return err
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("cannot create the sandbox (are unprivileged user namespaces enabled?): %s", msg)
+	}
+	return nil
+}
+
+// mountEntry is one line of /proc/self/mountinfo.
+type mountEntry struct {
+	point string
+	flags uintptr // per-mount flags, e.g. MS_NOSUID
+}
+
+// mountOptionFlags maps per-mount options to the flags a bind remount must repeat: the kernel
+// refuses to clear them inside a user namespace.
+var mountOptionFlags = map[string]uintptr{
+	"ro":          syscall.MS_RDONLY,
+	"nosuid":      syscall.MS_NOSUID,
+	"nodev":       syscall.MS_NODEV,
+	"noexec":      syscall.MS_NOEXEC,
+	"noatime":     syscall.MS_NOATIME,
+	"nodiratime":  syscall.MS_NODIRATIME,
+	"relatime":    syscall.MS_RELATIME,
+	"strictatime": syscall.MS_STRICTATIME,
+}
+
+// readMountInfo parses /proc/self/mountinfo. When several mounts share a mount point only the
+// last, visible one is kept.
+func readMountInfo() ([]mountEntry, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	byPoint := map[string]int{}
+	var mounts []mountEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// id parent major:minor root mount-point options [optional fields] - fstype source super-options
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		m := mountEntry{point: unescapeMountPath(fields[4])}
+		for _, opt := range strings.Split(fields[5], ",") {
+			m.flags |= mountOptionFlags[opt]
+		}
+		if i, ok := byPoint[m.point]; ok {
+			mounts[i] = m
+			continue
+		}
+		byPoint[m.point] = len(mounts)
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath decodes the octal escapes (\040 for space, etc.) used in mountinfo paths.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// kernelMount reports whether a mount point belongs to /proc, /sys or /dev, whose remount
+// failures are tolerated: they are kernel interfaces, and writes to device nodes are not blocked
+// by a read-only mount anyway.
+func kernelMount(point string) bool {
+	for _, dir := range []string{"/proc", "/sys", "/dev"} {
+		if point == dir || strings.HasPrefix(point, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// mountFlagsAt returns the per-mount flags of the mount containing path.
+func mountFlagsAt(mounts []mountEntry, path string) uintptr {
+	best, flags := -1, uintptr(0)
+	for _, m := range mounts {
+		if (path == m.point || m.point == "/" || strings.HasPrefix(path, m.point+"/")) && len(m.point) > best {
+			best, flags = len(m.point), m.flags
+		}
+	}
+	return flags
+}
+
+// runSandboxInit runs in the new namespaces: it makes every mount read-only, puts a tmpfs on
+// /tmp, binds the workspace and other writable paths read-write, brings up loopback, applies the
+// resource limits and then runs the command without privileges (see runUnprivileged). It exits
+// with the command's status and only returns on failure.
+func runSandboxInit(args []string) error {
+	if len(args) < 3 {
+		return fmt.Errorf("usage: %s config program args...", sandboxInitArg)
+	}
+	var cfg SandboxConfig
+	if err := json.Unmarshal([]byte(args[0]), &cfg); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	program, argv := args[1], args[2:]
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	// Open the writable paths before the tmpfs can hide the ones under /tmp.
+	writable := map[string]int{}
+	for _, p := range append([]string{cfg.Workspace}, cfg.Writable...) {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		fd, err := syscall.Open(abs, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			if p == cfg.Workspace {
+				return fmt.Errorf("workspace %s: %w", abs, err)
+			}
+			continue // extra writable paths that don't exist are skipped
+		}
+		writable[abs] = fd
+	}
+
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	mounts, err := readMountInfo()
+	if err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		if m.point == "/proc" {
+			continue // runUnprivileged writes the nested namespace's id maps through it
+		}
+		err := syscall.Mount("", m.point, "", syscall.MS_REMOUNT|syscall.MS_BIND|syscall.MS_RDONLY|m.flags, "")
+		if err != nil && !kernelMount(m.point) {
+			return fmt.Errorf("remount %s read-only: %w", m.point, err)
+		}
+	}
+	if err := syscall.Mount("tmpfs", "/tmp", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("mount tmpfs on /tmp: %w", err)
+	}
+
+	// Bind parents before children so a writable path inside another stays visible.
+	paths := make([]string, 0, len(writable))
+	for p := range writable {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if err := os.MkdirAll(p, 0o755); err != nil {
+			return fmt.Errorf("writable path %s: %w", p, err)
+		}
+		source := fmt.Sprintf("/proc/self/fd/%d", writable[p])
+		if err := syscall.Mount(source, p, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind %s: %w", p, err)
+		}
+		flags := mountFlagsAt(mounts, p) &^ syscall.MS_RDONLY
+		if err := syscall.Mount("", p, "", syscall.MS_REMOUNT|syscall.MS_BIND|flags, ""); err != nil {
+			return fmt.Errorf("remount %s read-write: %w", p, err)
+		}
+		syscall.Close(writable[p])
+	}
+
+	if !cfg.Network {
+		if err := loopbackUp(); err != nil {
+			return fmt.Errorf("bring up loopback: %w", err)
+		}
+	}
+	limits := []struct {
+		resource int
+		value    int
+		scale    uint64
+	}{
+		{syscall.RLIMIT_CPU, cfg.CPUSeconds, 1},
+		{syscall.RLIMIT_AS, cfg.MemoryMB, 1 << 20},
+		{rlimitNproc, cfg.MaxProcesses, 1},
+	}
+	for _, l := range limits {
+		if l.value <= 0 {
+			continue
+		}
+		v := uint64(l.value) * l.scale
+		if err := syscall.Setrlimit(l.resource, &syscall.Rlimit{Cur: v, Max: v}); err != nil {
+			return fmt.Errorf("setrlimit %d: %w", l.resource, err)
+		}
+	}
+
+	// The old working directory may refer to the mount underneath a writable bind; enter it again.
+	if err := os.Chdir(cwd); err != nil {
+		return fmt.Errorf("working directory %s is not available in the sandbox: %w", cwd, err)
+	}
+	return runUnprivileged(program, argv)
+}
+
+// prSetNoNewPrivs is PR_SET_NO_NEW_PRIVS, which the syscall package does not define.
+const prSetNoNewPrivs = 38
+
+// runUnprivileged runs the command in a nested user and mount namespace as a non-root user, then
+// exits the way it did. The init is root in its namespace and owns the mounts it set up, so the
+// command could otherwise remount them read-write; in the nested namespace those mounts are
+// locked and the command has no capabilities. No-new-privileges keeps setuid and file-capability
+// binaries from raising them again.
+func runUnprivileged(program string, argv []string) error {
+	uid, gid := outerID("/proc/self/uid_map"), outerID("/proc/self/gid_map")
+	if uid == 0 {
+		uid = 65534 // nobody, when the agent itself runs as root
+	}
+	if gid == 0 {
+		gid = 65534
+	}
+	// prctl applies to the calling thread, which must be the one that starts the command.
+	runtime.LockOSThread()
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("set no_new_privs: %w", errno)
+	}
+	cmd := &exec.Cmd{
+		Path:   program,
+		Args:   argv,
+		Env:    os.Environ(),
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		SysProcAttr: &syscall.SysProcAttr{
+			Cloneflags:                 syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS,
+			UidMappings:                []syscall.SysProcIDMap{{ContainerID: uid, HostID: 0, Size: 1}},
+			GidMappings:                []syscall.SysProcIDMap{{ContainerID: gid, HostID: 0, Size: 1}},
+			GidMappingsEnableSetgroups: false,
+			Credential:                 &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), NoSetGroups: true},
+			Pdeathsig:                  syscall.SIGKILL,
+		},
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return err
+	}
+	status := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if status.Signaled() {
+		// Die of the same signal so the caller sees how the command ended.
+		signal.Reset()
+		syscall.Kill(os.Getpid(), status.Signal())
+		os.Exit(128 + int(status.Signal()))
+	}
+	os.Exit(status.ExitStatus())
+	return nil
+}
+
+// outerID returns the id that id 0 of this namespace maps to in the parent namespace, read from
+// a uid_map or gid_map file, or 0 if it cannot be read.
+func outerID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 || fields[0] != "0" {
+		return 0
+	}
+	id, _ := strconv.Atoi(fields[1])
+	return id
+}
+
+// loopbackUp sets the IFF_UP flag on lo, so servers and tests can still use 127.0.0.1.
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+	var req [40]byte // struct ifreq: interface name, then a union holding the flags
+	copy(req[:syscall.IFNAMSIZ], "lo")
+	binary.NativeEndian.PutUint16(req[syscall.IFNAMSIZ:], uint16(syscall.IFF_UP|syscall.IFF_RUNNING))
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&req[0]))); errno != 0 {
+		return errno
+	}
+	return nil
+}
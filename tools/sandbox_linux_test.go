@@ -0,0 +1,62 @@
+//go:build linux
+
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	SandboxInit()
+	os.Exit(m.Run())
+}
+
+// sandboxed runs a shell script in the sandbox and returns its combined output.
+func sandboxed(t *testing.T, script string) (string, error) {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", script)
+	if err := sandboxCommand(cmd); err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestSandboxBlocksRemountAndOutsideWrites(t *testing.T) {
+	workspace := t.TempDir()
+	if err := SetSandbox(&SandboxConfig{Workspace: workspace}); err != nil {
+		t.Skip(err)
+	}
+	defer SetSandbox(nil)
+	// Not under /tmp, which is a private tmpfs in the sandbox.
+	outside, err := os.MkdirTemp(".", "sandbox-outside-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside, _ = filepath.Abs(outside)
+	defer os.RemoveAll(outside)
+
+	if out, err := sandboxed(t, "mount -o remount,rw,bind /"); err == nil {
+		t.Errorf("remounting / read-write succeeded in the sandbox:\n%s", out)
+	}
+	if out, err := sandboxed(t, "mount -o remount,rw,bind / 2>/dev/null; touch "+outside+"/f"); err == nil {
+		t.Errorf("writing outside the workspace succeeded in the sandbox:\n%s", out)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "f")); err == nil {
+		t.Error("a sandboxed command created a file outside the workspace")
+	}
+	if out, err := sandboxed(t, "touch "+workspace+"/f"); err != nil {
+		t.Errorf("writing in the workspace failed: %v\n%s", err, out)
+	}
+	out, err := sandboxed(t, "grep CapEff /proc/self/status")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	if fields := strings.Fields(out); len(fields) != 2 || strings.Trim(fields[1], "0") != "" {
+		t.Errorf("sandboxed command has capabilities: %s", out)
+	}
+}
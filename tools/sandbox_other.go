@@ -0,0 +1,22 @@
+//go:build !linux
+
+package tools
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errSandboxUnsupported = errors.New("the sandbox needs Linux namespaces and is not available on this system")
+
+func wrapSandbox(cmd *exec.Cmd, cfg SandboxConfig) error {
+	return errSandboxUnsupported
+}
+
+func checkSandbox(cfg SandboxConfig) error {
+	return errSandboxUnsupported
+}
+
+func runSandboxInit(args []string) error {
+	return errSandboxUnsupported
+}
@@ -12,15 +12,16 @@ import (
 // SearchFileDefinition is the tool that searches for a file by name and returns its path if found.
 var SearchFileDefinition = ToolDefinition{
 	Name:        "searchFile",
-	Description: "Search for a file by name under a given directory. Returns the relative path(s) of any matching file(s), or a message if not found. Use this when you need to locate a file but only know its name.",
+	Description: "Search for a file by name under a given directory. Returns the relative path(s) of any matching file(s), or a message if not found. Use this when you need to locate a file but only know its name. Files ignored by .gitignore or .agentignore and directories like .git and node_modules are skipped unless includeIgnored is set.",
 	InputSchema: SearchFileInputSchema,
 	Function:    SearchFile,
 }
 
 // SearchFileInput is the JSON shape for the searchFile tool.
 type SearchFileInput struct {
-	FileName string `json:"fileName" jsonschema_description:"The name of the file to search for (e.g. main.go or README.md)."`
-	RootPath string `json:"rootPath" jsonschema_description:"The directory to search in, relative to the working directory. Default is the current directory (.)."`
+	FileName       string `json:"fileName" jsonschema_description:"The name of the file to search for (e.g. main.go or README.md)."`
+	RootPath       string `json:"rootPath" jsonschema_description:"The directory to search in, relative to the working directory. Default is the current directory (.)."`
+	IncludeIgnored bool   `json:"includeIgnored" jsonschema_description:"If true, also search files ignored by .gitignore/.agentignore and junk directories such as node_modules (.git is always skipped)."`
 }
 
 // SearchFileInputSchema is the Anthropic tool input schema for searchFile.
@@ -51,10 +52,7 @@ func SearchFile(input json.RawMessage) (string, error) {
 	}
 
 	var matches []string
-	err = filepath.WalkDir(rootPath, func(path string, d os.DirEntry, walkErr error) error {
-		if walkErr != nil {
-			return walkErr
-		}
+	err = walkTree(rootPath, searchFileInput.IncludeIgnored, func(path string, d os.DirEntry) error {
 		if d.IsDir() {
 			return nil
 		}
@@ -0,0 +1,297 @@
+// Package tools provides the persistent shell tool for the agent.
+package tools
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShellDefinition is the tool that runs commands in a long-lived shell session.
+var ShellDefinition = ToolDefinition{
+	Name:        "shell",
+	Description: "Run a command in a persistent shell session (bash if available, else sh) whose state carries over between calls: cd, exported variables, sourced scripts and activated environments. Returns combined stdout and stderr, exit code, duration and the working directory afterwards. On timeout the command is killed and the shell restarted in its last directory (variables are lost). Set reset to start a fresh shell. Use runCommand for isolated one-off commands.",
+	InputSchema: ShellInputSchema,
+	Function:    Shell,
+}
+
+// ShellInput is the JSON shape for the shell tool.
+type ShellInput struct {
+	Command        string `json:"command" jsonschema_description:"The command to run in the session shell; may span several lines."`
+	TimeoutSeconds int    `json:"timeoutSeconds" jsonschema_description:"Kill the command after this many seconds; default 120, maximum 3600."`
+	MaxOutputBytes int    `json:"maxOutputBytes" jsonschema_description:"Bytes of output kept (first and last halves); default 16000."`
+	Reset          bool   `json:"reset" jsonschema_description:"If true, discard the current shell and start a fresh one in the agent's directory before running command (command may then be empty)."`
+}
+
+// ShellInputSchema is the Anthropic tool input schema for shell.
+var ShellInputSchema = GenerateSchema[ShellInput]()
+
+// minShellOutput keeps the head/tail halves large enough to hold the end-of-command marker.
+const minShellOutput = 2000
+
+// shellSession is the long-lived shell. Commands are written to its stdin wrapped in eval, followed
+// by a printf of a per-command marker with the exit status and working directory; the marker line
+// in the output marks where the command's output ends.
+type shellSession struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	nonce   string
+	seq     int
+	exited  chan struct{}
+	program string
+
+	mu      sync.Mutex
+	output  *headTailBuffer
+	window  []byte         // recent output, searched for the marker
+	marker  string         // marker of the running command
+	markRE  *regexp.Regexp // matches its marker line, capturing status and working directory
+	done    chan struct{}  // closed when the marker is seen
+	status  int
+	workDir string
+}
+
+var (
+	shellMu      sync.Mutex // held while a command runs, so calls are serialized
+	shellCurrent atomic.Pointer[shellSession]
+)
+
+// shellResult is the outcome of one command in the session shell.
+type shellResult struct {
+	output   string
+	status   int
+	workDir  string // working directory after the command
+	timedOut bool
+}
+
+// startShell starts a shell in dir.
+func startShell(dir string) (*shellSession, error) {
+	program := "sh"
+	if path, err := exec.LookPath("bash"); err == nil {
+		program = path
+	}
+	args := []string{}
+	if strings.HasSuffix(program, "bash") {
+		args = []string{"--noprofile", "--norc"}
+	}
+	cmd := exec.Command(program, args...)
+	cmd.Dir = dir
+	cmd.Env = commandEnv(nil)
+	startInProcessGroup(cmd)
+	if err := sandboxCommand(cmd); err != nil {
+		return nil, err
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 8)
+	rand.Read(nonce)
+	s := &shellSession{cmd: cmd, stdin: stdin, nonce: hex.EncodeToString(nonce), exited: make(chan struct{}), program: program, workDir: dir}
+	// The same writer for both streams makes exec share one pipe, keeping their order.
+	cmd.Stdout, cmd.Stderr = s, s
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		cmd.Wait()
+		close(s.exited)
+	}()
+	return s, nil
+}
+
+func (s *shellSession) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.output == nil {
+		return len(p), nil // output between commands, e.g. from background jobs
+	}
+	s.output.Write(p)
+	s.window = append(s.window, p...)
+	if m := s.markRE.FindSubmatch(s.window); m != nil {
+		s.status, _ = strconv.Atoi(string(m[1]))
+		s.workDir = string(m[2])
+		close(s.done)
+		s.output = nil
+		return len(p), nil
+	}
+	if len(s.window) > 8*minShellOutput {
+		s.window = append(s.window[:0], s.window[len(s.window)-minShellOutput:]...)
+	}
+	return len(p), nil
+}
+
+// run executes command and waits for its marker, the shell's exit, or timeout. The result's output
+// has the marker removed.
+func (s *shellSession) run(command string, timeout time.Duration, limit int) (shellResult, error) {
+	s.mu.Lock()
+	s.seq++
+	s.marker = fmt.Sprintf("__agent_shell_%s_%d__", s.nonce, s.seq)
+	s.markRE = regexp.MustCompile(regexp.QuoteMeta(s.marker) + ` (\d+) (.*)\n`)
+	out := newHeadTailBuffer(limit)
+	s.output, s.window, s.done = out, nil, make(chan struct{})
+	done := s.done
+	s.mu.Unlock()
+
+	// stdin is the shell's script, so the command must not read from it.
+	script := fmt.Sprintf("eval %s </dev/null\nprintf '\\n%%s %%d %%s\\n' %s \"$?\" \"$PWD\"\n", shellQuote(command), s.marker)
+	if _, err := io.WriteString(s.stdin, script); err != nil {
+		return shellResult{}, err
+	}
+	var result shellResult
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-s.exited:
+		// Give the output copier a moment to deliver the shell's last words.
+		time.Sleep(50 * time.Millisecond)
+	case <-timer.C:
+		result.timedOut = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.output = nil
+	result.output = out.String()
+	if i := strings.LastIndex(result.output, "\n"+s.marker); i >= 0 {
+		result.output = result.output[:i]
+	}
+	result.status, result.workDir = s.status, s.workDir
+	return result, nil
+}
+
+// close kills the shell and everything it started.
+func (s *shellSession) close() {
+	s.stdin.Close()
+	killProcessGroup(s.cmd)
+	select {
+	case <-s.exited:
+	case <-time.After(commandKillGracePeriod):
+	}
+}
+
+// shellQuote quotes s as a single sh word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Shell implements the shell tool.
+func Shell(input json.RawMessage) (string, error) {
+	var shellInput ShellInput
+	if err := json.Unmarshal(input, &shellInput); err != nil {
+		return "", fmt.Errorf("shell input: %w", err)
+	}
+	command := strings.TrimSpace(shellInput.Command)
+	if command == "" && !shellInput.Reset {
+		return "", fmt.Errorf("shell: command is required")
+	}
+	timeout := defaultCommandTimeout
+	if shellInput.TimeoutSeconds > 0 {
+		timeout = min(time.Duration(shellInput.TimeoutSeconds)*time.Second, maxCommandTimeout)
+	}
+	limit := max(shellInput.MaxOutputBytes, minShellOutput)
+	if shellInput.MaxOutputBytes <= 0 {
+		limit = defaultCommandOutput
+	}
+
+	shellMu.Lock()
+	defer shellMu.Unlock()
+	var notes []string
+	s := shellCurrent.Load()
+	if s != nil && shellInput.Reset {
+		s.close()
+		s = nil
+		notes = append(notes, "Shell reset.")
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("shell: %w", err)
+	}
+	if s != nil && s.isExited() {
+		dir = s.lastWorkDir()
+		notes = append(notes, fmt.Sprintf("The previous shell had exited; started a new one in %s.", dir))
+		s = nil
+	}
+	if s == nil {
+		if s, err = startShell(dir); err != nil {
+			shellCurrent.Store(nil)
+			return "", fmt.Errorf("shell: %w", err)
+		}
+		shellCurrent.Store(s)
+	}
+	if command == "" {
+		return strings.Join(append(notes, fmt.Sprintf("%s started in %s.", s.program, dir)), "\n"), nil
+	}
+	// A syntax error would end a non-interactive shell, so check the command first.
+	if out, err := exec.Command(s.program, "-n", "-c", command).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("shell: syntax error, nothing was run:\n%s", strings.TrimSpace(string(out)))
+	}
+	if _, err := checkCommandRisk("shell", command, s.lastWorkDir()); err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	result, err := s.run(command, timeout, limit)
+	duration := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		s.close()
+		shellCurrent.CompareAndSwap(s, nil)
+		return "", fmt.Errorf("shell: the shell is gone (%v); the next call starts a new one", err)
+	}
+	var b strings.Builder
+	switch {
+	case result.timedOut:
+		// The command runs in the shell's own process group, so stopping it means restarting the shell.
+		s.close()
+		next, err := startShell(result.workDir)
+		if err != nil {
+			next = nil
+		}
+		shellCurrent.CompareAndSwap(s, next)
+		fmt.Fprintf(&b, "exitCode: -1\nduration: %s\nkilled: true (timed out after %s; the shell was restarted in %s and its variables were lost)\n", duration, timeout, result.workDir)
+	case s.isExited():
+		shellCurrent.CompareAndSwap(s, nil)
+		fmt.Fprintf(&b, "exitCode: %d\nduration: %s\nThe shell exited; the next call starts a new one in %s.\n", s.cmd.ProcessState.ExitCode(), duration, result.workDir)
+	default:
+		fmt.Fprintf(&b, "exitCode: %d\nduration: %s\ncwd: %s\n", result.status, duration, result.workDir)
+	}
+	for _, note := range notes {
+		b.WriteString(note + "\n")
+	}
+	fmt.Fprintf(&b, "output:\n%s", result.output)
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// lastWorkDir is the shell's working directory as of its last completed command.
+func (s *shellSession) lastWorkDir() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.workDir
+}
+
+// isExited reports whether the shell process has ended.
+func (s *shellSession) isExited() bool {
+	select {
+	case <-s.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeShell ends the persistent shell, if one is running. It does not wait for a running
+// command, which sees the shell exit.
+func closeShell() {
+	if s := shellCurrent.Swap(nil); s != nil {
+		s.close()
+	}
+}
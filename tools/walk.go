@@ -0,0 +1,135 @@
+// Package tools provides the directory walker shared by the tree-walking tools.
+package tools
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// junkDirs are directory names skipped by tree-walking tools unless ignored files are requested:
+// dependency trees and caches that flood results without ever being project sources. Build output
+// directories such as bin or dist are left to .gitignore, since some projects keep sources there.
+var junkDirs = map[string]bool{
+	"node_modules": true,
+	"__pycache__":  true,
+	".venv":        true,
+}
+
+// treeWalker tracks the ignore rules in effect while walking a directory tree.
+type treeWalker struct {
+	top       string
+	baseRules []ignoreRule
+	dirRules  map[string][]ignoreRule
+}
+
+// walkTree walks root like filepath.WalkDir, calling fn for every entry below root (not root itself).
+// .git directories are always skipped. Unless includeIgnored is set, entries matched by .gitignore
+// files (nested, with negations and directory patterns), .git/info/exclude, the project's
+// .agentignore, or named like a junk directory are skipped too. Unreadable entries are skipped.
+// fn may return filepath.SkipDir or filepath.SkipAll.
+func walkTree(root string, includeIgnored bool, fn func(path string, d fs.DirEntry) error) error {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	w := &treeWalker{dirRules: map[string][]ignoreRule{}}
+	if !includeIgnored {
+		w.loadAncestors(absRoot)
+	}
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if path == root {
+				return walkErr
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		abs := filepath.Join(absRoot, rel)
+		if rel == "." {
+			if d.IsDir() && !includeIgnored {
+				w.loadDir(abs)
+			}
+			return nil
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !includeIgnored {
+			if w.ignored(abs, d) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				w.loadDir(abs)
+			}
+		}
+		return fn(path, d)
+	})
+}
+
+// loadAncestors finds the repository root above absRoot and loads the repo-wide ignore files plus
+// every .gitignore between the repository root and absRoot.
+func (w *treeWalker) loadAncestors(absRoot string) {
+	w.top = findRepoRoot(absRoot)
+	if w.top == "" {
+		w.top = absRoot
+	}
+	w.baseRules = append(parseIgnoreFile(filepath.Join(w.top, ".git", "info", "exclude"), w.top),
+		parseIgnoreFile(filepath.Join(w.top, projectIgnoreFile), w.top)...)
+	rel, err := filepath.Rel(w.top, absRoot)
+	if err != nil || rel == "." {
+		return
+	}
+	dir := w.top
+	parts := strings.Split(rel, string(filepath.Separator))
+	for _, part := range parts[:len(parts)-1] {
+		w.loadDir(dir)
+		dir = filepath.Join(dir, part)
+	}
+	w.loadDir(dir)
+}
+
+// loadDir loads the .gitignore in dir, if any.
+func (w *treeWalker) loadDir(dir string) {
+	if _, ok := w.dirRules[dir]; ok {
+		return
+	}
+	w.dirRules[dir] = parseIgnoreFile(filepath.Join(dir, ".gitignore"), dir)
+}
+
+// ignored applies the rules in precedence order (repo-wide files first, then .gitignore files from
+// the top down); the last matching rule decides, so a nested !pattern can re-include a path.
+func (w *treeWalker) ignored(abs string, d fs.DirEntry) bool {
+	if d.IsDir() && junkDirs[d.Name()] {
+		return true
+	}
+	isDir := d.IsDir()
+	result := false
+	apply := func(rules []ignoreRule) {
+		for _, r := range rules {
+			if r.match(abs, isDir) {
+				result = !r.negate
+			}
+		}
+	}
+	apply(w.baseRules)
+	rel, err := filepath.Rel(w.top, filepath.Dir(abs))
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return result
+	}
+	dir := w.top
+	apply(w.dirRules[dir])
+	if rel != "." {
+		for _, part := range strings.Split(rel, string(filepath.Separator)) {
+			dir = filepath.Join(dir, part)
+			apply(w.dirRules[dir])
+		}
+	}
+	return result
+}